@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/spf13/cobra"
+)
+
+const defaultBatchSuffix = ".fortified"
+
+var batch = &cobra.Command{Use: "batch", Short: "Encrypt or decrypt many files under one key"}
+
+var (
+	flagBatchGlobs  []string
+	flagBatchList   string
+	flagBatchSuffix string
+	flagBatchJobs   int
+)
+
+func init() {
+	root.AddCommand(batch)
+}
+
+func initFlagsBatch(c *cobra.Command, suffixUsage string) {
+	c.Flags().StringArrayVarP(&flagBatchGlobs, "glob", "g", nil,
+		"Glob pattern of the input files, directories are skipped (repeatable)")
+	c.Flags().StringVarP(&flagBatchList, "list", "l", "",
+		"Path of a file listing the input files one per line, or '-' for stdin")
+	c.Flags().StringVarP(&flagBatchSuffix, "suffix", "s", defaultBatchSuffix, suffixUsage)
+	c.Flags().IntVarP(&flagBatchJobs, "jobs", "j", runtime.NumCPU(), "Number of files processed at the same time")
+	c.MarkFlagsOneRequired("glob", "list")
+}
+
+type batchResult struct {
+	input  string
+	output string
+	err    error
+}
+
+// batchInputs collects the input files matching the globs and listed in the list file, without duplicates
+func batchInputs(globs []string, list string) (inputs []string, err error) {
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			inputs = append(inputs, name)
+		}
+	}
+	for _, glob := range globs {
+		var matches []string
+		if matches, err = filepath.Glob(glob); err != nil {
+			return nil, fmt.Errorf("%s: %w", glob, err)
+		}
+		for _, name := range matches {
+			if stat, e := os.Stat(name); e == nil && stat.IsDir() {
+				continue
+			}
+			add(name)
+		}
+	}
+	if list != "" {
+		var in *os.File
+		var iCloseFn func()
+		if in, iCloseFn, err = files.OpenInputFile(list); err != nil {
+			return
+		}
+		defer iCloseFn()
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			if name := strings.TrimSpace(scanner.Text()); name != "" && !strings.HasPrefix(name, "#") {
+				add(name)
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			return
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, errors.New("no input files")
+	}
+	return
+}
+
+// runBatch calls fn for every input with at most jobs calls at the same time,
+// then prints a report line for every input in order and fails if any call failed
+func runBatch(w io.Writer, inputs []string, jobs int, fn func(input string) (output string, err error)) error {
+	results := make([]batchResult, len(inputs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(jobs, len(inputs))) {
+		wg.Go(func() {
+			for i := range indexes {
+				results[i].input = inputs[i]
+				results[i].output, results[i].err = fn(inputs[i])
+			}
+		})
+	}
+	for i := range inputs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			_, _ = fmt.Fprintf(w, "FAILED %s: %v\n", r.input, r.err)
+		} else {
+			_, _ = fmt.Fprintf(w, "OK     %s --> %s\n", r.input, r.output)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "%d of %d files OK\n", len(inputs)-failed, len(inputs))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(inputs))
+	}
+	return nil
+}
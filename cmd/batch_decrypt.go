@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	c := &cobra.Command{
+		Short:        "Decrypt many fortified input files, unlocking every distinct key once",
+		Use:          "decrypt (-g <glob> | -l <list-file>) [flags] <key1> [key2] ...",
+		Args:         cobra.MinimumNArgs(0),
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			inputs, err := batchInputs(flagBatchGlobs, flagBatchList)
+			if err != nil {
+				return err
+			}
+			return batchDecrypt(os.Stdout, inputs, flagBatchSuffix, flagBatchJobs, args)
+		},
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Required Arguments:
+  <key1>   Path to the first secret share file or private key file if cipher key kind of the input files is 'rsa' or 'ecdh'
+           (no key file if cipher key kind of the input files is 'passphrase')
+  [key2]   [Required cipher key kind of the input files is 'sss'] Path to the second secret share file
+  ...      Additional paths to secret share files (all files remain unmodified)
+%s`, c.UsageTemplate(), keySourcesUsage))
+	batch.AddCommand(c)
+	initFlagHelp(c)
+	initFlagTruncate(c)
+	initFlagVerbose(c)
+	initFlagPassphrase(c)
+	initFlagsBatch(c, "Suffix removed from the path of every input file to make the path of its output file")
+}
+
+// batchKeys keeps the fortifiers whose keys are set up, by the digest of their data keys
+type batchKeys struct {
+	mu   sync.Mutex
+	keys map[string]*fortifier.Fortifier
+	args []string
+}
+
+// fortifierOf returns a fortifier with the key of meta set up, which is unlocked once for all files sharing it
+func (b *batchKeys) fortifierOf(meta *fortifier.Metadata) (f *fortifier.Fortifier, err error) {
+	digest := meta.KeyDigest()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if digest != "" {
+		if f = b.keys[digest]; f != nil {
+			return f.Clone(), nil
+		}
+	}
+	if f, _, err = newFortifier(meta.Key, meta, b.args); err != nil {
+		return
+	}
+	if err = f.SetupKey(); err != nil {
+		return
+	}
+	if digest != "" {
+		b.keys[digest] = f
+		return f.Clone(), nil
+	}
+	return
+}
+
+func batchDecrypt(w io.Writer, inputs []string, suffix string, jobs int, args []string) error {
+	files.SetVerbose(flagVerbose)
+	if suffix == "" {
+		return errors.New("empty suffix of the input files")
+	}
+	keys := &batchKeys{keys: make(map[string]*fortifier.Fortifier), args: args}
+	return runBatch(w, inputs, jobs, func(input string) (output string, err error) {
+		if output = strings.TrimSuffix(input, suffix); output == input {
+			return "", fmt.Errorf("has no suffix %s", suffix)
+		}
+		var in, out *os.File
+		var iCloseFn, oCloseFn func()
+		if in, iCloseFn, err = files.OpenInputFile(input); err != nil {
+			return
+		}
+		defer iCloseFn()
+		layout := &fortifier.FileLayout{}
+		if err = layout.ReadHeadIn(in); err != nil {
+			return
+		}
+		meta := layout.Metadata()
+		var f *fortifier.Fortifier
+		if f, err = keys.fortifierOf(meta); err != nil {
+			return
+		}
+		var dec fortifier.Decrypter
+		if dec = fortifier.NewDecrypter(meta.Mode, f); dec == nil {
+			return output, fmt.Errorf("unknown cipher mode name: %s", meta.Mode)
+		}
+		if out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate); err != nil {
+			return
+		}
+		defer oCloseFn()
+		return output, dec.DecryptFile(in, out, layout)
+	})
+}
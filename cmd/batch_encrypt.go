@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	c := &cobra.Command{
+		Short:        "Encrypt many input files under one key, each with its own IV and head",
+		Use:          "encrypt (-g <glob> | -l <list-file>) [flags] <key1> [key2] ...",
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			inputs, err := batchInputs(flagBatchGlobs, flagBatchList)
+			if err != nil {
+				return err
+			}
+			return batchEncrypt(os.Stdout, inputs, flagBatchSuffix, flagBatchJobs, flagEncKey, flagEncMode, args)
+		},
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Arguments:
+  <key1>   Path to the first secret share file or public key file if -k/--k is 'rsa' or 'ecdh'
+           (no key file if -k/--k is 'passphrase')
+  [key2]   [Required if -k/--k is 'sss' and <key1> is given] Path to the second secret share file
+  ...      Additional paths to secret share files, or public key files of more recipients if -k/--k is 'rsa'
+           (all files remain unmodified)
+%s`, c.UsageTemplate(), keySourcesUsage))
+	batch.AddCommand(c)
+	initFlagHelp(c)
+	initFlagTruncate(c)
+	initFlagVerbose(c)
+	initFlagsBatch(c, "Suffix appended to the path of every input file to make the path of its output file")
+	initFlagsEncrypter(c)
+}
+
+// batchEncrypt sets up one key, so that new secret shares are written once, and encrypts every input with it
+func batchEncrypt(w io.Writer, inputs []string, suffix string, jobs int, key, mode string, args []string) error {
+	files.SetVerbose(flagVerbose)
+	if suffix == "" {
+		return errors.New("empty suffix of the output files")
+	}
+	f, err := newEncryptFortifier(key, args)
+	if err != nil {
+		return err
+	}
+	if fortifier.NewEncrypter(fortifier.CipherModeName(mode), f) == nil {
+		return fmt.Errorf("unknown cipher mode name: %s", mode)
+	}
+	if err = f.SetupKey(); err != nil {
+		return err
+	}
+	return runBatch(w, inputs, jobs, func(input string) (output string, err error) {
+		if strings.HasSuffix(input, suffix) {
+			return "", fmt.Errorf("already has the suffix %s", suffix)
+		}
+		output = input + suffix
+		var in, out *os.File
+		var iCloseFn, oCloseFn func()
+		if in, iCloseFn, err = files.OpenInputFile(input); err != nil {
+			return
+		}
+		defer iCloseFn()
+		if out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate); err != nil {
+			return
+		}
+		defer oCloseFn()
+		return output, fortifier.NewEncrypter(fortifier.CipherModeName(mode), f.Clone()).EncryptFile(in, out)
+	})
+}
@@ -1,11 +1,24 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/i3ash/fortify/fortifier"
+	"github.com/i3ash/fortify/pkg/envfile"
+	"github.com/i3ash/fortify/sss"
 )
 
 func TestReadKeyFile(t *testing.T) {
@@ -168,6 +181,17 @@ func TestNewFortifier_RSA(t *testing.T) {
 	_ = rest
 }
 
+func TestNewFortifier_StdinKeyAndInput(t *testing.T) {
+	defer func(in string) { flagIn = in }(flagIn)
+	flagIn = "-"
+	if _, _, err := newFortifier("sss", nil, []string{"-", "fd:3"}); err == nil || !strings.Contains(err.Error(), "stdin") {
+		t.Errorf("expected error for a key read from stdin with the input, got %v", err)
+	}
+	if _, _, err := newDecrypter(os.Stdin, []string{" - "}); err == nil || !strings.Contains(err.Error(), "stdin") {
+		t.Errorf("expected error for a key read from stdin with the input, got %v", err)
+	}
+}
+
 func TestExecute_ZeroReturn(t *testing.T) {
 	// Execute() returns 0 on success - but root.Execute() needs proper setup
 	// Just check the function signature and basic behavior
@@ -186,4 +210,341 @@ func TestConstants(t *testing.T) {
 	if defaultRandomBytes != 32 {
 		t.Errorf("default random bytes should be 32, got %d", defaultRandomBytes)
 	}
-}
\ No newline at end of file
+}
+
+// fortifyWithRsa encrypts plaintext for a new RSA key in dir and returns the paths of the file and private key
+func fortifyWithRsa(t *testing.T, dir string, plaintext []byte) (out, priPath string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+	}
+	pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	priPath = filepath.Join(dir, "key.pem")
+	os.WriteFile(priPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+
+	in := filepath.Join(dir, "plain.txt")
+	out = filepath.Join(dir, "fortified.data")
+	os.WriteFile(in, plaintext, 0644)
+	fi, _ := os.Open(in)
+	defer fi.Close()
+	fo, _ := os.Create(out)
+	defer fo.Close()
+	f := fortifier.NewFortifierWithRsa(false, nil, pub)
+	if err = fortifier.NewEncrypter(fortifier.CipherModeAes256CTR, f).EncryptFile(fi, fo); err != nil {
+		t.Fatalf("EncryptFile failed: %v", err)
+	}
+	return
+}
+
+func TestInspect_Json(t *testing.T) {
+	out, priPath := fortifyWithRsa(t, t.TempDir(), []byte("inspect me"))
+	var buf bytes.Buffer
+	if err := inspect(&buf, out, true, nil); err != nil {
+		t.Fatalf("inspect failed: %v", err)
+	}
+	var report inspectReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if report.DataLength != 10 || report.Metadata.Key != fortifier.CipherKeyKindRSA || report.HeadVerified != nil {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	buf.Reset()
+	if err := inspect(&buf, out, false, []string{priPath}); err != nil {
+		t.Fatalf("inspect with key failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "verified") {
+		t.Errorf("expected the head to be verified:\n%s", buf.String())
+	}
+}
+
+func TestVerify_ExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	out, priPath := fortifyWithRsa(t, dir, bytes.Repeat([]byte("verify me "), 100))
+	data, _ := os.ReadFile(out)
+	tampered := filepath.Join(dir, "tampered.data")
+	data[len(data)-1] ^= 0x01
+	os.WriteFile(tampered, data, 0600)
+	truncated := filepath.Join(dir, "truncated.data")
+	os.WriteFile(truncated, data[:len(data)-10], 0600)
+	plain := filepath.Join(dir, "plain.txt")
+
+	var buf bytes.Buffer
+	if err := verify(&buf, []string{out}, []string{priPath}); err != nil {
+		t.Fatalf("verify failed: %v\n%s", err, buf.String())
+	}
+	cases := []struct {
+		inputs []string
+		code   int
+	}{
+		{[]string{out, tampered}, exitCodeVerifyChecksum},
+		{[]string{truncated, out}, exitCodeVerifyLength},
+		{[]string{plain}, exitCodeVerifyNotFile},
+		{[]string{tampered, plain, truncated}, exitCodeVerifyChecksum},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		err := verify(&buf, c.inputs, []string{priPath})
+		var exit *exitError
+		if !errors.As(err, &exit) || exit.code != c.code {
+			t.Errorf("%v: expected exit code %d, got %v\n%s", c.inputs, c.code, err, buf.String())
+		}
+	}
+}
+
+func TestBatch_EncryptDecrypt(t *testing.T) {
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	pubPath := filepath.Join(dir, "pub.pem")
+	priPath := filepath.Join(dir, "pri.pem")
+	os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+	os.WriteFile(priPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+
+	var inputs, outputs []string
+	contents := make(map[string][]byte)
+	for i := range 8 {
+		path := filepath.Join(dir, "conf", strings.Repeat("x", i+1)+".conf")
+		contents[path] = bytes.Repeat([]byte{byte('a' + i)}, 1000*i+1)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		os.WriteFile(path, contents[path], 0600)
+		inputs = append(inputs, path)
+		outputs = append(outputs, path+defaultBatchSuffix)
+	}
+	var buf bytes.Buffer
+	err = batchEncrypt(&buf, inputs, defaultBatchSuffix, 3, "rsa", "aes256-gcm-stream", []string{pubPath})
+	if err != nil {
+		t.Fatalf("batchEncrypt failed: %v\n%s", err, buf.String())
+	}
+	for _, path := range inputs {
+		os.Remove(path)
+	}
+
+	buf.Reset()
+	missing := filepath.Join(dir, "missing"+defaultBatchSuffix)
+	err = batchDecrypt(&buf, append(outputs, missing), defaultBatchSuffix, 3, []string{priPath})
+	if err == nil || !strings.Contains(buf.String(), "FAILED "+missing) {
+		t.Errorf("expected the missing file to fail:\n%s", buf.String())
+	}
+	for path, content := range contents {
+		if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, content) {
+			t.Errorf("%s: decrypted content mismatch: %v", path, err)
+		}
+	}
+}
+
+func TestDetectInterpreter(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh in PATH")
+	}
+	cases := []struct {
+		explicit string
+		head     string
+		args     []string
+	}{
+		{"", "\x7fELF\x02\x01\x01", nil},
+		{"", "echo no shebang\n", []string{sh}},
+		{"", "#!" + sh + "\necho\n", []string{sh}},
+		{"", "#! " + sh + " -e -u \necho\n", []string{sh, "-e -u"}},
+		{"sh -s", "#!/no/such/interpreter\n", []string{sh, "-s"}},
+		{"sh", "\x7fELF", []string{sh}},
+	}
+	for _, c := range cases {
+		interp, err := detectInterpreter(c.explicit, []byte(c.head))
+		if err != nil {
+			t.Fatalf("%q: detectInterpreter failed: %v", c.head, err)
+		}
+		if c.args == nil {
+			if interp != nil {
+				t.Errorf("%q: expected no interpreter, got %+v", c.head, interp)
+			}
+			continue
+		}
+		if interp == nil {
+			t.Fatalf("%q: expected an interpreter", c.head)
+		}
+		if argv := interp.argv("", nil); strings.Join(argv, "|") != strings.Join(c.args, "|") {
+			t.Errorf("%q: expected %q, got %q", c.head, c.args, argv)
+		}
+	}
+	if _, err = detectInterpreter("", []byte("#!/no/such/interpreter\n")); err == nil {
+		t.Error("expected error for a missing interpreter")
+	}
+	if _, err = detectInterpreter("", []byte("#!"+strings.Repeat("x", programHeadSize))); err == nil {
+		t.Error("expected error for a shebang line which is too long")
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	env := []string{"PATH=/bin", "HOME=/root", "EMPTY="}
+	env = mergeEnv(env, []envfile.Variable{{Name: "HOME", Value: "/home/app"}, {Name: "TOKEN", Value: "a=b"},
+		{Name: "TOKEN", Value: "c"}})
+	expect := []string{"PATH=/bin", "HOME=/home/app", "EMPTY=", "TOKEN=c"}
+	if strings.Join(env, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected %q, got %q", expect, env)
+	}
+}
+
+func TestRun_ExitCode(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil || runtime.GOOS == "windows" {
+		t.Skip("no unix sh")
+	}
+	cases := map[string]int{
+		"exit 0":        0,
+		"exit 5":        5,
+		"kill -TERM $$": 128 + int(syscall.SIGTERM),
+	}
+	for script, code := range cases {
+		err := run("sh", []string{"sh", "-c", script}, nil, nil, nil)
+		var exit *exitError
+		switch {
+		case code == 0 && err != nil:
+			t.Errorf("%q: expected no error, got %v", script, err)
+		case code != 0 && (!errors.As(err, &exit) || exit.code != code):
+			t.Errorf("%q: expected exit code %d, got %v", script, code, err)
+		}
+	}
+	if err := run("/no/such/program", []string{"/no/such/program"}, nil, nil, nil); err == nil {
+		t.Error("expected error for a missing program")
+	}
+}
+
+func TestPromptMnemonics(t *testing.T) {
+	parts, err := sss.Split([]byte("a secret told in words"), 3, 2)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	var shares []string
+	for i := range parts {
+		words, err := sss.EncodeMnemonic(&parts[i])
+		if err != nil {
+			t.Fatalf("EncodeMnemonic failed: %v", err)
+		}
+		shares = append(shares, strings.Join(words[:6], " ")+"\n"+strings.Join(words[6:], " ")+"\n")
+	}
+	typo := strings.Replace(shares[1], strings.Fields(shares[1])[0], "zoo", 1)
+	input := shares[0] + "\n" + typo + "\n" + shares[0] + "\n" + shares[2] + "\n"
+	var prompt bytes.Buffer
+	ms, err := promptMnemonics(strings.NewReader(input), &prompt, true)
+	if err != nil {
+		t.Fatalf("promptMnemonics failed: %v", err)
+	}
+	if got := strings.Count(prompt.String(), "Invalid share"); got != 2 {
+		t.Errorf("expected 2 invalid shares, got %d:\n%s", got, prompt.String())
+	}
+	secret, err := sss.CombineMnemonics(ms)
+	if err != nil || string(secret) != "a secret told in words" {
+		t.Errorf("expected the secret, got %q, %v", secret, err)
+	}
+	if _, err = promptMnemonics(strings.NewReader(typo), &prompt, false); err == nil {
+		t.Error("expected error for a mistyped share without retry")
+	}
+	if _, err = promptMnemonics(strings.NewReader(shares[0]), &prompt, true); err == nil {
+		t.Error("expected error when the input ends before the threshold")
+	}
+}
+
+func TestDecryptDir_TamperedLeavesNothing(t *testing.T) {
+	truncate := flagTruncate
+	flagTruncate = false
+	defer func() { flagTruncate = truncate }()
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	pubPath := filepath.Join(dir, "pub.pem")
+	priPath := filepath.Join(dir, "pri.pem")
+	os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+	os.WriteFile(priPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+	src := filepath.Join(dir, "src")
+	os.MkdirAll(filepath.Join(src, "sub"), 0700)
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0600)
+	os.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("b"), 0600)
+	out := filepath.Join(dir, "tree.fortified")
+	flagEncRecursive = true
+	err = encrypt(src, out, "rsa", "aes256-ctr", []string{pubPath})
+	flagEncRecursive = false
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+
+	// The padding after the archive is only checked once all the files are extracted
+	data, _ := os.ReadFile(out)
+	data[len(data)-1] ^= 0x01
+	tampered := filepath.Join(dir, "tampered.fortified")
+	os.WriteFile(tampered, data, 0600)
+	missing := filepath.Join(dir, "missing")
+	if err = decryptDir(tampered, missing, []string{priPath}); err == nil {
+		t.Error("expected error for a tampered container")
+	}
+	if _, err = os.Lstat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the directory not to be left, got %v", err)
+	}
+	existing := filepath.Join(dir, "existing")
+	os.MkdirAll(existing, 0700)
+	os.WriteFile(filepath.Join(existing, "kept.txt"), []byte("kept"), 0600)
+	if err = decryptDir(tampered, existing, []string{priPath}); err == nil {
+		t.Error("expected error for a tampered container")
+	}
+	if entries, _ := os.ReadDir(existing); len(entries) != 1 || entries[0].Name() != "kept.txt" {
+		t.Errorf("expected nothing left in the directory, got %v", entries)
+	}
+
+	if err = decryptDir(out, existing, []string{priPath}); err != nil {
+		t.Fatalf("decryptDir failed: %v", err)
+	}
+	for name, content := range map[string]string{"kept.txt": "kept", "a.txt": "a", "sub/b.txt": "b"} {
+		if got, err := os.ReadFile(filepath.Join(existing, name)); err != nil || string(got) != content {
+			t.Errorf("%s: expected %q, got %q, %v", name, content, got, err)
+		}
+	}
+	if err = decryptDir(out, existing, []string{priPath}); err == nil {
+		t.Error("expected existing files to be kept")
+	}
+	if entries, _ := os.ReadDir(existing); len(entries) != 3 {
+		t.Errorf("expected no staging directory left, got %v", entries)
+	}
+}
+
+func TestDecrypt_TruncatedRemovesOutput(t *testing.T) {
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	priPath := filepath.Join(dir, "pri.pem")
+	os.WriteFile(priPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+	// Encrypted to a pipe, with the checksums and the data length in the trailer
+	f := fortifier.NewFortifierWithRsa(false, nil, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	var buf bytes.Buffer
+	plaintext := bytes.Repeat([]byte("piped "), 1000)
+	if err = fortifier.NewEncrypter(fortifier.CipherModeAes256CTR, f).Encrypt(bytes.NewReader(plaintext), &buf); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	truncated := filepath.Join(dir, "truncated.fortified")
+	os.WriteFile(truncated, buf.Bytes()[:buf.Len()-100], 0600)
+	out := filepath.Join(dir, "out.txt")
+	if err = decrypt(truncated, out, []string{priPath}); err == nil {
+		t.Error("expected error for a truncated input")
+	}
+	if _, err = os.Lstat(out); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the output file to be removed, got %v", err)
+	}
+}
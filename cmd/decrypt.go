@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/i3ash/fortify/files"
@@ -10,31 +11,42 @@ import (
 )
 
 func init() {
-	var o string
+	var o, extractTo string
 	c := &cobra.Command{
-		Short: "Decrypt the fortified input file",
+		Short: "Decrypt the fortified input file, or extract the fortified directory tree with --extract-to",
 		Use:   "decrypt -i <input-file> [flags] <key1> [key2] ...",
-		Args:  cobra.MinimumNArgs(1),
+		Args:  cobra.MinimumNArgs(0),
 		RunE: func(_ *cobra.Command, args []string) error {
+			if extractTo != "" {
+				return decryptDir(flagIn, extractTo, args)
+			}
 			return decrypt(flagIn, o, args)
 		},
 	}
 	c.SetUsageTemplate(fmt.Sprintf(`%s
 Required Arguments:
-  <key1>   Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa'
+  <key1>   Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa' or 'ecdh'
+           (no key file if cipher key kind of <input-file> is 'passphrase')
   [key2]   [Required cipher key kind of <input-file> is 'sss'] Path to the second secret share file
   ...      Additional paths to secret share files (all files remain unmodified)
-`, c.UsageTemplate()))
+%s`, c.UsageTemplate(), keySourcesUsage))
 	root.AddCommand(c)
 	initFlagHelp(c)
 	initFlagTruncate(c)
 	initFlagVerbose(c)
-	initFlagIn(c, "[Required] Path of the fortified/encrypted input file")
+	initFlagPassphrase(c)
+	initFlagIn(c, "[Required] Path of the fortified/encrypted input file, or '-' for stdin")
 	_ = c.MarkFlagRequired("in")
-	c.Flags().StringVarP(&o, "out", "o", "output.data", "Path of the output decrypted file")
+	c.Flags().StringVarP(&o, "out", "o", "output.data", "Path of the output decrypted file, or '-' for stdout")
+	c.Flags().StringVarP(&extractTo, "extract-to", "", "",
+		"Path of the directory to extract the fortified directory tree into, made by 'encrypt -r'")
+	c.MarkFlagsMutuallyExclusive("out", "extract-to")
 }
 
 func decrypt(input, output string, args []string) (err error) {
+	if err = checkVerboseOutput(output); err != nil {
+		return
+	}
 	files.SetVerbose(flagVerbose)
 	var in, out *os.File
 	var iCloseFn, oCloseFn func()
@@ -42,7 +54,73 @@ func decrypt(input, output string, args []string) (err error) {
 		return
 	}
 	defer iCloseFn()
-	layout := &fortifier.FileLayout{}
+	layout, dec, err := newDecrypter(in, args)
+	if err != nil {
+		return
+	}
+	if out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate); err != nil {
+		return
+	}
+	// A regular output file is removed on failure, as it holds data which is not verified, as of a truncated input
+	stat, statErr := out.Stat()
+	remove := out != os.Stdout && statErr == nil && stat.Mode().IsRegular()
+	defer func() {
+		oCloseFn()
+		if err != nil && remove {
+			_ = os.Remove(out.Name())
+		}
+	}()
+	return dec.DecryptFile(in, out, layout)
+}
+
+// decryptDir extracts the directory tree fortified by 'encrypt -r' into dir.
+// The files are extracted while the data is decrypted, out of sight in dir until the data is verified,
+// so that nothing is left in dir if an error is returned.
+func decryptDir(input, dir string, args []string) (err error) {
+	files.SetVerbose(flagVerbose)
+	in, iCloseFn, err := files.OpenInputFile(input)
+	if err != nil {
+		return
+	}
+	defer iCloseFn()
+	layout, dec, err := newDecrypter(in, args)
+	if err != nil {
+		return
+	}
+	if content := layout.Metadata().Content; content != fortifier.ContentKindTar {
+		return fmt.Errorf("%s is not a fortified directory tree", input)
+	}
+	pr, pw := io.Pipe()
+	var decErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		decErr = dec.Decrypt(in, pw, layout)
+		_ = pw.CloseWithError(decErr)
+	}()
+	err = files.ExtractArchiveVerified(pr, dir, flagTruncate, func() error {
+		// Read the padding after the archive, so that the checksum covers all the data
+		if _, err := io.Copy(io.Discard, pr); err != nil {
+			return err
+		}
+		<-done
+		return decErr
+	})
+	_ = pr.CloseWithError(err)
+	<-done
+	if err == nil {
+		err = decErr
+	}
+	return
+}
+
+func newDecrypter(in *os.File, args []string) (layout *fortifier.FileLayout, dec fortifier.Decrypter, err error) {
+	if in == os.Stdin {
+		if err = checkStdinKeys(files.StdStream, args); err != nil {
+			return
+		}
+	}
+	layout = &fortifier.FileLayout{}
 	if err = layout.ReadHeadIn(in); err != nil {
 		return
 	}
@@ -54,14 +132,8 @@ func decrypt(input, output string, args []string) (err error) {
 	if f, _, err = newFortifier(meta.Key, meta, args); err != nil {
 		return
 	}
-	var dec fortifier.Decrypter
 	if dec = fortifier.NewDecrypter(meta.Mode, f); dec == nil {
 		err = fmt.Errorf("unknown cipher mode name: %s", meta.Mode)
-		return
-	}
-	if out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate); err != nil {
-		return
 	}
-	defer oCloseFn()
-	return dec.DecryptFile(in, out, layout)
+	return
 }
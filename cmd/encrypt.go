@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/i3ash/fortify/files"
@@ -9,47 +10,73 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var flagEncOut, flagEncKey, flagEncMode string
+var flagEncOut, flagEncKey, flagEncMode, flagEncCompress string
+var flagEncSss, flagEncRecursive bool
+var flagEncSssParts, flagEncSssThreshold uint8
+var flagEncArgon2Time, flagEncArgon2Memory uint32
+var flagEncArgon2Threads uint8
 
 func init() {
 	c := &cobra.Command{
-		Short: "Encrypt an input file",
+		Short: "Encrypt an input file, or an input directory with -r/--recursive",
 		Use:   "encrypt -i <input-file> [flags] <key1> [key2] ...",
 		RunE: func(_ *cobra.Command, args []string) error {
 			return encrypt(flagIn, flagEncOut, flagEncKey, flagEncMode, args)
 		},
 	}
 	c.SetUsageTemplate(fmt.Sprintf(`%s
-Required Arguments:
-  <key1>   Path to the first secret share file or public key file if -k/--k is 'rsa'
-  [key2]   [Required if -k/--k is 'sss'] Path to the second secret share file
-  ...      Additional paths to secret share files (all files remain unmodified)
-`, c.UsageTemplate()))
+Arguments:
+  <key1>   Path to the first secret share file or public key file if -k/--k is 'rsa' or 'ecdh'
+           (no key file if -k/--k is 'passphrase')
+  [key2]   [Required if -k/--k is 'sss' and <key1> is given] Path to the second secret share file
+  ...      Additional paths to secret share files, or public key files of more recipients if -k/--k is 'rsa'
+           (all files remain unmodified)
+%s`, c.UsageTemplate(), keySourcesUsage))
 	root.AddCommand(c)
 	initFlagHelp(c)
 	initFlagTruncate(c)
 	initFlagVerbose(c)
-	initFlagIn(c, "[Required] Path of the input file")
+	initFlagIn(c, "[Required] Path of the input file, or '-' for stdin")
 	_ = c.MarkFlagRequired("in")
+	c.Flags().BoolVarP(&flagEncRecursive, "recursive", "r", false,
+		"Pack the input directory tree into one fortified file, to be extracted by 'decrypt --extract-to'")
 	c.Flags().StringVarP(&flagEncOut, "out", "o", "fortified.data",
-		"Path of the output fortified/encrypted file")
+		"Path of the output fortified/encrypted file, or '-' for stdout")
+	initFlagsEncrypter(c)
+}
+
+// initFlagsEncrypter registers the flags of the cipher key kind and mode of a new fortified file
+func initFlagsEncrypter(c *cobra.Command) {
 	c.Flags().StringVarP(&flagEncKey, "key", "k", fortifier.CipherKeyKindSSS.String(),
-		"Cipher key kind name, options: [sss|rsa]")
+		"Cipher key kind name, options: [sss|rsa|ecdh|passphrase]")
+	c.Flags().BoolVarP(&flagEncSss, "sss", "", false,
+		"Also split the data key into new secret shares if -k/--key is not 'sss'")
+	initFlagPartsAndThreshold(c, &flagEncSssParts, &flagEncSssThreshold, defaultEncSssParts, defaultEncSssThreshold)
+	initFlagPassphrase(c)
+	c.Flags().Uint32VarP(&flagEncArgon2Time, "argon2-time", "", fortifier.DefaultArgon2Time,
+		"Argon2id time cost (number of passes) if -k/--key is 'passphrase'")
+	c.Flags().Uint32VarP(&flagEncArgon2Memory, "argon2-memory", "", fortifier.DefaultArgon2Memory/1024,
+		"Argon2id memory cost in MiB if -k/--key is 'passphrase'")
+	c.Flags().Uint8VarP(&flagEncArgon2Threads, "argon2-threads", "", fortifier.DefaultArgon2Threads,
+		"Argon2id parallelism if -k/--key is 'passphrase'")
 	c.Flags().StringVarP(&flagEncMode, "mode", "m", fortifier.CipherModeAes256CTR.String(),
-		"Cipher mode name, options: [aes256-ctr|aes256-ofb|aes256-cfb]")
+		"Cipher mode name, options: [aes256-ctr|aes256-ofb|aes256-cfb|aes256-gcm-stream|xchacha20-poly1305-stream]")
+	c.Flags().StringVarP(&flagEncCompress, "compress", "z", "none",
+		"Compression of the data before encryption, options: [none|gzip|zstd]")
 }
 
 func encrypt(input, output, key, mode string, args []string) (err error) {
-	files.SetVerbose(flagVerbose)
-	var f *fortifier.Fortifier
-	if f, _, err = newFortifier(fortifier.CipherKeyKind(key), nil, args); err != nil {
+	if err = checkVerboseOutput(output); err != nil {
 		return
 	}
+	files.SetVerbose(flagVerbose)
 	var enc fortifier.Encrypter
-	if enc = fortifier.NewEncrypter(fortifier.CipherModeName(mode), f); enc == nil {
-		err = fmt.Errorf("unknown cipher mode name: %s", mode)
+	if enc, err = newEncrypter(key, mode, args); err != nil {
 		return
 	}
+	if flagEncRecursive {
+		return encryptDir(enc, input, output)
+	}
 	var in, out *os.File
 	var iCloseFn, oCloseFn func()
 	if in, iCloseFn, err = files.OpenInputFile(input); err != nil {
@@ -62,3 +89,75 @@ func encrypt(input, output, key, mode string, args []string) (err error) {
 	defer oCloseFn()
 	return enc.EncryptFile(in, out)
 }
+
+// encryptDir packs the directory tree at input into a tar archive, which is encrypted as the data of output
+func encryptDir(enc fortifier.Encrypter, input, output string) (err error) {
+	var stat os.FileInfo
+	if stat, err = os.Stat(input); err != nil {
+		return
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", input)
+	}
+	out, oCloseFn, err := files.OpenOutputFile(output, flagTruncate)
+	if err != nil {
+		return
+	}
+	defer oCloseFn()
+	// The output file in the input directory would be packed into itself
+	var exclude []os.FileInfo
+	if stat, err = out.Stat(); err == nil && stat.Mode().IsRegular() {
+		exclude = append(exclude, stat)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(files.WriteArchive(pw, input, exclude...))
+	}()
+	err = enc.Encrypt(pr, out)
+	_ = pr.CloseWithError(err)
+	return
+}
+
+func newEncrypter(key, mode string, args []string) (fortifier.Encrypter, error) {
+	f, err := newEncryptFortifier(key, args)
+	if err != nil {
+		return nil, err
+	}
+	enc := fortifier.NewEncrypter(fortifier.CipherModeName(mode), f)
+	if enc == nil {
+		return nil, fmt.Errorf("unknown cipher mode name: %s", mode)
+	}
+	return enc, nil
+}
+
+// newEncryptFortifier makes the fortifier of a new fortified file with the flags of initFlagsEncrypter
+func newEncryptFortifier(key string, args []string) (*fortifier.Fortifier, error) {
+	kind := fortifier.CipherKeyKind(key)
+	compression, err := fortifier.ParseCompression(flagEncCompress)
+	if err != nil {
+		return nil, err
+	}
+	if kind == fortifier.CipherKeyKindPassphrase {
+		if flagEncArgon2Memory < 1 || flagEncArgon2Memory > fortifier.MaxArgon2Memory/1024 {
+			return nil, fmt.Errorf("--argon2-memory must be between 1 and %d MiB", fortifier.MaxArgon2Memory/1024)
+		}
+		if flagEncArgon2Time < 1 || flagEncArgon2Time > fortifier.MaxArgon2Time {
+			return nil, fmt.Errorf("--argon2-time must be between 1 and %d", fortifier.MaxArgon2Time)
+		}
+	}
+	f, _, err := newFortifier(kind, nil, args)
+	if err != nil {
+		return nil, err
+	}
+	f.SetCompression(compression)
+	if flagEncRecursive {
+		f.SetContent(fortifier.ContentKindTar)
+	}
+	if kind == fortifier.CipherKeyKindPassphrase {
+		f.SetArgon2Cost(flagEncArgon2Time, flagEncArgon2Memory*1024, flagEncArgon2Threads)
+	}
+	if (kind == fortifier.CipherKeyKindSSS && len(args) == 0) || (kind != fortifier.CipherKeyKindSSS && flagEncSss) {
+		f.SplitKey(flagEncSssParts, flagEncSssThreshold, flagTruncate)
+	}
+	return f, nil
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -23,27 +26,40 @@ const keyListFile = "/dev/shm/keys/k_fortify"
 
 var cleanupOnce sync.Once
 var cleanupDelaySeconds = 5
+var flagAllowDiskFallback bool
 
 func init() {
 	c := &cobra.Command{
 		Short: "Execute a decrypted program from the fortified file",
 		Use:   "execute -i <input-file> [flags] <key1> [key2] ... [-- [arg1] [arg2] ...]",
 		Args:  cobra.MinimumNArgs(0),
-		RunE:  func(_ *cobra.Command, args []string) error { return execute(flagIn, args) },
+		RunE: func(c *cobra.Command, args []string) error {
+			c.SilenceUsage = true
+			return silenceProgramExit(c, execute(flagIn, args))
+		},
 	}
 	c.SetUsageTemplate(fmt.Sprintf(`%s
 Required Arguments:
-  <key1>   Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa'
+  <key1>   Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa' or 'ecdh'
+           (no key file if cipher key kind of <input-file> is 'passphrase')
   [key2]   [Required cipher key kind of <input-file> is 'sss'] Path to the second secret share file
   ...      Additional paths to secret share files (all files remain unmodified)
-`, c.UsageTemplate()))
+%s`, c.UsageTemplate(), keySourcesUsage))
 	root.AddCommand(c)
 	initFlagHelp(c)
 	initFlagVerbose(c)
+	initFlagPassphrase(c)
 	initFlagIn(c, "[Required] Path of the fortified/encrypted input file")
 	_ = c.MarkFlagRequired("in")
 	c.Flags().IntVarP(&cleanupDelaySeconds, "cleanup-delay", "", 5,
 		"Number of seconds to wait before performing the cleanup operation")
+	c.Flags().StringVarP(&flagInterpreter, "interpreter", "", "",
+		"Interpreter command of the decrypted script, instead of its shebang line or 'sh' if it is not executable")
+	c.Flags().BoolVarP(&flagScriptStdin, "script-stdin", "", false,
+		"Feed the decrypted script to the interpreter on stdin, instead of a path to /dev/fd (e.g. --interpreter 'sh -s')")
+	c.Flags().BoolVarP(&flagAllowDiskFallback, "allow-disk-fallback", "", false,
+		"Write the decrypted program to a temporary file if it fails to execute from memory")
+	initFlagSupervise(c)
 	if cleanupDelaySeconds < 1 {
 		cleanupDelaySeconds = 1
 	}
@@ -74,15 +90,62 @@ func execute(input string, args []string) error {
 	if f, rest, err = newFortifier(meta.Key, meta, merge); err != nil {
 		return err
 	}
+	// The fortifier keeps its own copy of the key material, which need not stay in memory for the life of the program
+	files.ForgetSources()
 	var dec fortifier.Decrypter
 	if dec = fortifier.NewDecrypter(meta.Mode, f); dec == nil {
 		err = fmt.Errorf("unknown cipher mode name: %s", meta.Mode)
 		return err
 	}
+	r := bufio.NewReaderSize(in, 128*1024)
+	name := filepath.Base(in.Name())
+	var interp *interpreter
+	// The program is decrypted in memory and executed from there, so it never hits a filesystem.
+	// Otherwise it falls back to a file which is removed once the program has started.
+	var program io.Reader
+	if mem, memErr := createMemfd(name); memErr == nil {
+		defer func() { _ = mem.Close() }()
+		if err = dec.Decrypt(r, mem, layout); err != nil {
+			return fmt.Errorf("failed to decrypt program: %w", err)
+		}
+		if interp, err = detectInterpreter(flagInterpreter, readProgramHead(mem)); err != nil {
+			return fmt.Errorf("failed to detect interpreter: %w", err)
+		}
+		if memErr = sealMemfd(mem); memErr == nil {
+			switch {
+			case interp == nil:
+				if flagSupervise {
+					return run(memfdPath(mem), append([]string{name}, rest...), nil, nil, nil)
+				}
+				memErr = syscall.Exec(memfdPath(mem), append([]string{name}, rest...), os.Environ())
+			case flagScriptStdin:
+				if _, err = mem.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				return run(interp.path, interp.argv("", rest), nil, mem, nil)
+			default:
+				if memErr = keepOpenOnExec(mem); memErr == nil {
+					if flagSupervise {
+						return run(interp.path, interp.argv(fdPath(mem), rest), nil, nil, nil)
+					}
+					memErr = syscall.Exec(interp.path, interp.argv(fdPath(mem), rest), os.Environ())
+				}
+			}
+		}
+		// The plaintext is in memory, and goes to a filesystem only if it is allowed to
+		if !flagAllowDiskFallback {
+			return fmt.Errorf("failed to execute program in memory: %w "+
+				"(--allow-disk-fallback writes it to a temporary file)", memErr)
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: failed to execute program in memory: %v, writing it to a temporary file\n", memErr)
+		program = io.NewSectionReader(mem, 0, math.MaxInt64)
+	} else if flagVerbose {
+		_, _ = fmt.Fprintf(os.Stderr, "Failed to create program in memory: %v\n", memErr)
+	}
 	var out *os.File
 	var command string
 	if docker {
-		command = mountBinDir + "/" + filepath.Base(in.Name())
+		command = mountBinDir + "/" + name
 		out, err = os.Create(command)
 	} else {
 		if out, err = os.CreateTemp("", tempFilePrefix); err == nil {
@@ -91,38 +154,83 @@ func execute(input string, args []string) error {
 	}
 	defer func() { cleanupOnce.Do(func() { if out != nil { cleanup(out) } }) }()
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Failed to create file: %v\n", err)
-		return nil
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if program != nil {
+		_, err = io.Copy(out, program)
+	} else if err = dec.Decrypt(r, out, layout); err == nil {
+		interp, err = detectInterpreter(flagInterpreter, readProgramHead(out))
 	}
-	r := bufio.NewReaderSize(in, 128*1024)
-	err = dec.Decrypt(r, out, layout)
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Failed to decrypt program: %v\n", err)
-		cleanupOnce.Do(func() { if out != nil { cleanup(out) } })
-		return nil
+		return fmt.Errorf("failed to decrypt program: %w", err)
 	}
 	path := out.Name()
 	_ = out.Close()
+	if interp != nil {
+		// The interpreter only reads the script, which also works on a mount without exec permission
+		if !flagScriptStdin {
+			return run(interp.path, interp.argv(command, rest), nil, nil, out)
+		}
+		var script *os.File
+		if script, err = os.Open(path); err != nil {
+			return fmt.Errorf("failed to open script: %w", err)
+		}
+		defer func() { _ = script.Close() }()
+		return run(interp.path, interp.argv("", rest), nil, script, out)
+	}
 	if err = permit(path); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Failed to permit: %v\n", err)
 	}
 	argv := append([]string{command}, rest...)
-	if err = syscall.Exec(command, argv, os.Environ()); err == nil {
-		return nil
-	}
-	var wg sync.WaitGroup
-	var process *os.Process
-	chanSignal := make(chan os.Signal, 1)
-	signal.Notify(chanSignal, os.Interrupt, syscall.SIGTERM)
-	if process, err = start(command, out, &wg, chanSignal, rest...); err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "Failed to run program: %v\n", err)
-		cleanupOnce.Do(func() { cleanup(out) })
-		return nil
-	}
-	sig := <-chanSignal
-	_ = process.Signal(sig)
-	wg.Wait()
-	return err
+	if !flagSupervise {
+		if err = syscall.Exec(command, argv, os.Environ()); err == nil {
+			return nil
+		}
+	}
+	return run(command, argv, nil, nil, out)
+}
+
+// run starts the program at path with argv, like syscall.Exec does, and with env and stdin if they are not nil,
+// forwards the signals to it and waits for it to exit, with an exitError of its exit code if it fails.
+// The file out is removed once the program has started. In supervisor mode the program is supervised instead.
+func run(path string, argv, env []string, stdin, out *os.File) error {
+	if flagSupervise {
+		return supervise(path, argv, env, stdin, out)
+	}
+	signals := make(chan os.Signal, 16)
+	notifySignals(signals)
+	defer signal.Stop(signals)
+	cmd, err := start(path, argv, env, stdin, out, nil)
+	if err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	for {
+		select {
+		case sig := <-signals:
+			// The program is in the process group of fortify
+			if forwardedSignal(sig, true) {
+				_ = cmd.Process.Signal(sig)
+			}
+		case err = <-done:
+			return programExitError(err)
+		}
+	}
+}
+
+// programExitError returns an exitError with the exit code of the program which failed with err,
+// or 128 plus the number of the signal which killed it, like shells do
+func programExitError(err error) error {
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		return err
+	}
+	code := exit.ExitCode()
+	if status, ok := exit.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		code = 128 + int(status.Signal())
+	}
+	return &exitError{code: code, err: err}
 }
 
 func permit(path string) error {
@@ -137,27 +245,27 @@ func permit(path string) error {
 	return nil
 }
 
-func start(command string, out *os.File, wg *sync.WaitGroup, chanSignal chan os.Signal, arg ...string) (*os.Process, error) {
-	cmd := exec.Command(command, arg...)
+func start(path string, argv, env []string, stdin, out *os.File, attr *syscall.SysProcAttr) (*exec.Cmd, error) {
+	cmd := exec.Command(path, argv[1:]...)
+	// The program gets the same argv[0] as when it replaces fortify, not the path it is run from
+	cmd.Args[0] = argv[0]
+	cmd.Env = env
+	cmd.SysProcAttr = attr
 	cmd.Stdin = os.Stdin
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Start(); err != nil {
-		return cmd.Process, fmt.Errorf("failed to start program: %v", err)
+		return nil, fmt.Errorf("failed to start program: %w", err)
 	}
-	select {
-	case <-time.After(time.Duration(cleanupDelaySeconds) * time.Second):
-		cleanupOnce.Do(func() { cleanup(out) })
+	if out != nil {
+		time.AfterFunc(time.Duration(cleanupDelaySeconds)*time.Second, func() {
+			cleanupOnce.Do(func() { cleanup(out) })
+		})
 	}
-	wg.Add(1)
-	go func(wg *sync.WaitGroup) {
-		if err := cmd.Wait(); err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "Failed: %v\n", err)
-		}
-		wg.Done()
-		chanSignal <- syscall.SIGTERM
-	}(wg)
-	return cmd.Process, nil
+	return cmd, nil
 }
 
 func cleanup(out *os.File) {
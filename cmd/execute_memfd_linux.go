@@ -0,0 +1,49 @@
+//go:build linux
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const memfdSeals = unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+
+// createMemfd creates an anonymous file in memory to hold the decrypted program,
+// which is executable even if vm.memfd_noexec asks for non-executable ones by default
+func createMemfd(name string) (*os.File, error) {
+	flags := unix.MFD_CLOEXEC | unix.MFD_ALLOW_SEALING
+	fd, err := unix.MemfdCreate(name, flags|unix.MFD_EXEC)
+	if errors.Is(err, unix.EINVAL) {
+		// Kernels before 6.3 know no MFD_EXEC, and create executable ones anyway
+		fd, err = unix.MemfdCreate(name, flags)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memfd_create: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "memfd:"+name), nil
+}
+
+// sealMemfd seals the program in file against any change
+func sealMemfd(file *os.File) error {
+	if _, err := unix.FcntlInt(file.Fd(), unix.F_ADD_SEALS, memfdSeals); err != nil {
+		return fmt.Errorf("seal memfd: %w", err)
+	}
+	return nil
+}
+
+// memfdPath returns the path to execute the program in file, as fexecve does
+func memfdPath(file *os.File) string {
+	return fmt.Sprintf("/proc/self/fd/%d", file.Fd())
+}
+
+// keepOpenOnExec keeps file open in the program executed next, for an interpreter to read the script in it
+func keepOpenOnExec(file *os.File) error {
+	if _, err := unix.FcntlInt(file.Fd(), unix.F_SETFD, 0); err != nil {
+		return fmt.Errorf("keep memfd open: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+var errMemfdUnsupported = errors.New("memfd is only supported on linux")
+
+func createMemfd(string) (*os.File, error) {
+	return nil, errMemfdUnsupported
+}
+
+func sealMemfd(*os.File) error {
+	return errMemfdUnsupported
+}
+
+func memfdPath(*os.File) string {
+	return ""
+}
+
+func keepOpenOnExec(*os.File) error {
+	return errMemfdUnsupported
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultInterpreter runs a program which is neither a known executable format nor has a shebang line,
+// just like shells do for programs the system cannot execute
+const defaultInterpreter = "sh"
+
+// programHeadSize is the number of bytes read from the start of a program to detect its interpreter
+const programHeadSize = 256
+
+var flagInterpreter string
+var flagScriptStdin bool
+
+// executableMagics are the starts of the executable formats which are not scripts
+var executableMagics = [][]byte{
+	[]byte("\x7fELF"),        // ELF
+	[]byte("MZ"),             // PE
+	{0xfe, 0xed, 0xfa, 0xce}, // Mach-O 32-bit
+	{0xfe, 0xed, 0xfa, 0xcf}, // Mach-O 64-bit
+	{0xce, 0xfa, 0xed, 0xfe}, // Mach-O 32-bit, little endian
+	{0xcf, 0xfa, 0xed, 0xfe}, // Mach-O 64-bit, little endian
+	{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal
+}
+
+// interpreter runs a decrypted script, which is read from a path or stdin
+type interpreter struct {
+	path string
+	args []string
+}
+
+// detectInterpreter returns the interpreter of a program starting with head, or nil if the program is executable.
+// The interpreter given by --interpreter comes first, then the one of the shebang line.
+func detectInterpreter(explicit string, head []byte) (*interpreter, error) {
+	var fields []string
+	if explicit = strings.TrimSpace(explicit); explicit != "" {
+		fields = strings.Fields(explicit)
+	} else if line, ok := bytes.CutPrefix(head, []byte("#!")); ok {
+		end := bytes.IndexByte(line, '\n')
+		if end < 0 {
+			return nil, fmt.Errorf("shebang line longer than %d bytes", programHeadSize)
+		}
+		// The rest of the line after the interpreter is one argument, as the kernel passes it
+		name, arg, _ := strings.Cut(strings.TrimSpace(string(line[:end])), " ")
+		if name == "" {
+			return nil, errors.New("empty shebang line")
+		}
+		fields = []string{name}
+		if arg = strings.TrimSpace(arg); arg != "" {
+			fields = append(fields, arg)
+		}
+	} else {
+		for _, magic := range executableMagics {
+			if bytes.HasPrefix(head, magic) {
+				return nil, nil
+			}
+		}
+		fields = []string{defaultInterpreter}
+	}
+	path, err := exec.LookPath(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("interpreter: %w", err)
+	}
+	return &interpreter{path: path, args: fields[1:]}, nil
+}
+
+// argv returns the arguments to run the script at path, or the script on stdin if path is empty
+func (i *interpreter) argv(path string, rest []string) []string {
+	argv := append([]string{i.path}, i.args...)
+	if path != "" {
+		argv = append(argv, path)
+	}
+	return append(argv, rest...)
+}
+
+// readProgramHead reads the start of a program to detect its interpreter
+func readProgramHead(r io.ReaderAt) []byte {
+	head := make([]byte, programHeadSize)
+	n, _ := r.ReadAt(head, 0)
+	return head[:n]
+}
+
+// fdPath returns the path which opens the file again through its descriptor, for the interpreter to read the script
+func fdPath(file *os.File) string {
+	return fmt.Sprintf("/dev/fd/%d", file.Fd())
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"filippo.io/age"
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+const exportFormatAge = "age"
+
+func init() {
+	var o, format string
+	var recipients, recipientFiles []string
+	c := &cobra.Command{
+		Short: "Export the fortified input file to another encryption format",
+		Use:   "export -i <input-file> --format age -r <recipient> [flags] <key1> [key2] ...",
+		Args:  cobra.MinimumNArgs(0),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return export(flagIn, o, format, recipients, recipientFiles, args)
+		},
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Required Arguments:
+  <key1>   Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa' or 'ecdh'
+           (no key file if cipher key kind of <input-file> is 'passphrase')
+  [key2]   [Required cipher key kind of <input-file> is 'sss'] Path to the second secret share file
+  ...      Additional paths to secret share files (all files remain unmodified)
+%s`, c.UsageTemplate(), keySourcesUsage))
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagTruncate(c)
+	initFlagVerbose(c)
+	initFlagPassphrase(c)
+	initFlagIn(c, "[Required] Path of the fortified/encrypted input file, or '-' for stdin")
+	_ = c.MarkFlagRequired("in")
+	c.Flags().StringVarP(&o, "out", "o", "output.age", "Path of the output exported file, or '-' for stdout")
+	c.Flags().StringVarP(&format, "format", "f", exportFormatAge, "Format of the output file, options: [age]")
+	c.Flags().StringArrayVarP(&recipients, "recipient", "r", nil,
+		"Recipient of the age file, an X25519 public key (age1...) or an ssh-rsa public key (repeatable)")
+	c.Flags().StringArrayVarP(&recipientFiles, "recipients-file", "R", nil,
+		"Path to a file of age recipients, one per line, or to an RSA public key file (repeatable)")
+}
+
+func export(input, output, format string, recipients, recipientFiles, args []string) (err error) {
+	if format != exportFormatAge {
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+	if err = checkVerboseOutput(output); err != nil {
+		return
+	}
+	var ars []age.Recipient
+	if ars, err = parseAgeRecipients(recipients, recipientFiles); err != nil {
+		return
+	}
+	files.SetVerbose(flagVerbose)
+	var in, out *os.File
+	var iCloseFn, oCloseFn func()
+	if in, iCloseFn, err = files.OpenInputFile(input); err != nil {
+		return
+	}
+	defer iCloseFn()
+	layout := &fortifier.FileLayout{}
+	if err = layout.ReadHeadIn(in); err != nil {
+		return
+	}
+	if flagVerbose {
+		fmt.Printf("%s\n", layout.String())
+	}
+	meta := layout.Metadata()
+	var f *fortifier.Fortifier
+	if f, _, err = newFortifier(meta.Key, meta, args); err != nil {
+		return
+	}
+	var dec fortifier.Decrypter
+	if dec = fortifier.NewDecrypter(meta.Mode, f); dec == nil {
+		err = fmt.Errorf("unknown cipher mode name: %s", meta.Mode)
+		return
+	}
+	if out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate); err != nil {
+		return
+	}
+	defer oCloseFn()
+	return fortifier.ExportAge(dec, in, out, layout, ars...)
+}
+
+func parseAgeRecipients(recipients, recipientFiles []string) (ars []age.Recipient, err error) {
+	for _, r := range recipients {
+		var parsed []age.Recipient
+		if parsed, err = fortifier.ParseAgeRecipients([]byte(r)); err != nil {
+			return
+		}
+		ars = append(ars, parsed...)
+	}
+	for i := range recipientFiles {
+		var kb []byte
+		if kb, err = readKeyFile(recipientFiles[i:]); err != nil {
+			return
+		}
+		var parsed []age.Recipient
+		if parsed, err = fortifier.ParseAgeRecipients(kb); err != nil {
+			return
+		}
+		ars = append(ars, parsed...)
+	}
+	if len(ars) == 0 {
+		err = fmt.Errorf("at least one age recipient is required")
+	}
+	return
+}
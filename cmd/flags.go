@@ -1,15 +1,29 @@
 package cmd
 
 import (
+	"errors"
+	"strings"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/sss"
 	"github.com/spf13/cobra"
 )
 
 const (
-	defaultSssParts     = 5
-	defaultSssThreshold = 3
-	defaultRandomBytes  = 32
+	defaultSssParts        = 5
+	defaultSssThreshold    = 3
+	defaultRandomBytes     = 32
+	defaultEncSssParts     = 2
+	defaultEncSssThreshold = 2
 )
 
+// keySourcesUsage tells the sources of key files other than paths, which files.ReadSource reads
+const keySourcesUsage = `
+Key Sources:
+  Besides paths, key files are read from 'fd:<N>', 'env:<NAME>' or '-' (stdin), which need no filesystem,
+  and from 'file:<path>' for paths which would look like those
+`
+
 var (
 	flagVerbose      bool
 	flagTruncate     bool
@@ -18,6 +32,10 @@ var (
 	flagBytes        int
 	flagSssParts     uint8 = defaultSssParts
 	flagSssThreshold uint8 = defaultSssThreshold
+	flagPassEnv      string
+	flagPassFd       = -1
+	flagEncoding     string
+	flagVerifiable   bool
 )
 
 func initFlagVerbose(c *cobra.Command) {
@@ -25,6 +43,14 @@ func initFlagVerbose(c *cobra.Command) {
 		"Enable verbose mode to print more information to the terminal")
 }
 
+// checkVerboseOutput rejects verbose mode if its messages would be mixed into the output on stdout
+func checkVerboseOutput(output string) error {
+	if flagVerbose && strings.TrimSpace(output) == files.StdStream {
+		return errors.New("verbose mode is unavailable while writing the output to stdout")
+	}
+	return nil
+}
+
 func initFlagTruncate(c *cobra.Command) {
 	c.Flags().BoolVarP(&flagTruncate, "truncate", "T", false, "Truncate the output file(s) before write")
 }
@@ -33,11 +59,11 @@ func initFlagHelp(c *cobra.Command) {
 	c.Flags().BoolP("help", "h", false, "Show help message")
 }
 
-func initFlagPartsAndThreshold(c *cobra.Command) {
-	c.Flags().Uint8VarP(&flagSssParts, "parts", "p",
-		defaultSssParts, "Number of secret shares to generate")
-	c.Flags().Uint8VarP(&flagSssThreshold, "threshold", "t",
-		defaultSssThreshold, "Minimum number of shares required for secret recovery")
+func initFlagPartsAndThreshold(c *cobra.Command, parts, threshold *uint8, defParts, defThreshold uint8) {
+	c.Flags().Uint8VarP(parts, "parts", "p",
+		defParts, "Number of secret shares to generate")
+	c.Flags().Uint8VarP(threshold, "threshold", "t",
+		defThreshold, "Minimum number of shares required for secret recovery")
 }
 
 func initFlagIn(c *cobra.Command, usage string) {
@@ -51,3 +77,20 @@ func initFlagPrefix(c *cobra.Command, usage string) {
 func initFlagBytes(c *cobra.Command, value int, usage string) {
 	c.Flags().IntVarP(&flagBytes, "bytes", "b", value, usage)
 }
+
+func initFlagEncoding(c *cobra.Command) {
+	c.Flags().StringVarP(&flagEncoding, "encoding", "", string(sss.EncodingJson),
+		"Encoding of the generated secret shares, options: [json|binary|armor|qr|mnemonic]")
+}
+
+func initFlagVerifiable(c *cobra.Command) {
+	c.Flags().BoolVarP(&flagVerifiable, "verifiable", "", false,
+		"Generate verifiable secret shares, which carry commitments for 'sss verify' (not with mnemonics)")
+}
+
+func initFlagPassphrase(c *cobra.Command) {
+	c.Flags().StringVarP(&flagPassEnv, "passphrase-env", "", "",
+		"Name of the environment variable holding the passphrase if cipher key kind is 'passphrase'")
+	c.Flags().IntVarP(&flagPassFd, "passphrase-fd", "", -1,
+		"File descriptor to read the passphrase from if cipher key kind is 'passphrase'")
+}
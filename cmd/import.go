@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"filippo.io/age"
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var o string
+	var identityFiles []string
+	c := &cobra.Command{
+		Short: "Import an age encrypted input file as a fortified file",
+		Use:   "import -i <input-file> --identity <identity-file> [flags] <key1> [key2] ...",
+		RunE: func(_ *cobra.Command, args []string) error {
+			return importAge(flagIn, o, identityFiles, args)
+		},
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Arguments:
+  <key1>   Path to the first secret share file or public key file if -k/--k is 'rsa' or 'ecdh'
+           (no key file if -k/--k is 'passphrase')
+  [key2]   [Required if -k/--k is 'sss' and <key1> is given] Path to the second secret share file
+  ...      Additional paths to secret share files, or public key files of more recipients if -k/--k is 'rsa'
+           (all files remain unmodified)
+%s`, c.UsageTemplate(), keySourcesUsage))
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagTruncate(c)
+	initFlagVerbose(c)
+	initFlagIn(c, "[Required] Path of the age encrypted input file, or '-' for stdin")
+	_ = c.MarkFlagRequired("in")
+	c.Flags().StringVarP(&o, "out", "o", "fortified.data",
+		"Path of the output fortified/encrypted file, or '-' for stdout")
+	c.Flags().StringArrayVarP(&identityFiles, "identity", "I", nil,
+		"[Required] Path to an age identity file of X25519 secret keys, or to an RSA private key file (repeatable)")
+	_ = c.MarkFlagRequired("identity")
+	initFlagsEncrypter(c)
+}
+
+func importAge(input, output string, identityFiles, args []string) (err error) {
+	if err = checkVerboseOutput(output); err != nil {
+		return
+	}
+	var identities []age.Identity
+	for i := range identityFiles {
+		var kb []byte
+		if kb, err = readKeyFile(identityFiles[i:]); err != nil {
+			return
+		}
+		var parsed []age.Identity
+		if parsed, err = fortifier.ParseAgeIdentities(kb); err != nil {
+			return
+		}
+		identities = append(identities, parsed...)
+	}
+	files.SetVerbose(flagVerbose)
+	var enc fortifier.Encrypter
+	if enc, err = newEncrypter(flagEncKey, flagEncMode, args); err != nil {
+		return
+	}
+	var in, out *os.File
+	var iCloseFn, oCloseFn func()
+	if in, iCloseFn, err = files.OpenInputFile(input); err != nil {
+		return
+	}
+	defer iCloseFn()
+	if out, oCloseFn, err = files.OpenOutputFile(output, flagTruncate); err != nil {
+		return
+	}
+	defer oCloseFn()
+	return fortifier.ImportAge(enc, in, out, identities...)
+}
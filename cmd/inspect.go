@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+type inspectReport struct {
+	File           string              `json:"file"`
+	Magic          string              `json:"magic"`
+	Version        string              `json:"version"`
+	Trailer        bool                `json:"trailer"`
+	DataLength     uint64              `json:"dataLength"`
+	MetadataLength uint32              `json:"metadataLength"`
+	Checksum       string              `json:"checksum"`
+	HeadChecksum   string              `json:"headChecksum"`
+	HeadVerified   *bool               `json:"headVerified,omitempty"`
+	Metadata       *fortifier.Metadata `json:"metadata"`
+}
+
+func init() {
+	var asJson bool
+	c := &cobra.Command{
+		Short: "Print the head and metadata of the fortified input file",
+		Use:   "inspect -i <input-file> [flags] [key1] [key2] ...",
+		Args:  cobra.MinimumNArgs(0),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return inspect(os.Stdout, flagIn, asJson, args)
+		},
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Optional Arguments:
+  [key1]   Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa' or 'ecdh',
+           to verify the head checksum of <input-file> without decrypting the data
+  [key2]   Path to the second secret share file if cipher key kind of <input-file> is 'sss'
+  ...      Additional paths to secret share files (all files remain unmodified)
+%s`, c.UsageTemplate(), keySourcesUsage))
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagPassphrase(c)
+	initFlagIn(c, "[Required] Path of the fortified/encrypted input file, or '-' for stdin")
+	_ = c.MarkFlagRequired("in")
+	c.Flags().BoolVarP(&asJson, "json", "", false, "Print in JSON format")
+}
+
+func inspect(w io.Writer, input string, asJson bool, args []string) (err error) {
+	var in *os.File
+	var iCloseFn func()
+	if in, iCloseFn, err = files.OpenInputFile(input); err != nil {
+		return
+	}
+	defer iCloseFn()
+	layout := &fortifier.FileLayout{}
+	if err = layout.ReadHeadIn(in); err != nil {
+		return
+	}
+	if err = layout.ReadTailAfterData(in); err != nil {
+		return
+	}
+	meta := layout.Metadata()
+	report := &inspectReport{
+		File:           input,
+		Magic:          fmt.Sprintf("%X", layout.Magic()),
+		Version:        string(layout.Version()),
+		Trailer:        layout.HasTrailer(),
+		DataLength:     layout.DataLength(),
+		MetadataLength: layout.MetadataLength(),
+		Checksum:       fmt.Sprintf("%X", layout.Checksum()),
+		HeadChecksum:   fmt.Sprintf("%X", layout.HeadChecksum()),
+		Metadata:       meta,
+	}
+	var verifyErr error
+	if len(args) > 0 || flagPassEnv != "" || flagPassFd >= 0 {
+		var f *fortifier.Fortifier
+		if f, _, err = newFortifier(meta.Key, meta, args); err != nil {
+			return
+		}
+		verifyErr = f.VerifyHead(layout)
+		verified := verifyErr == nil
+		report.HeadVerified = &verified
+	}
+	if asJson {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(report); err != nil {
+			return
+		}
+	} else {
+		printInspectReport(w, report)
+	}
+	return verifyErr
+}
+
+func printInspectReport(w io.Writer, r *inspectReport) {
+	meta := r.Metadata
+	line := func(name string, value any) {
+		_, _ = fmt.Fprintf(w, "%-22s %v\n", name+":", value)
+	}
+	line("File", r.File)
+	line("Magic", r.Magic)
+	if r.Trailer {
+		line("Version", r.Version+" (checksums after the data)")
+	} else {
+		line("Version", r.Version)
+	}
+	line("Data Length", r.DataLength)
+	line("Cipher Mode", meta.Mode)
+	line("Cipher Key Kind", meta.Key)
+	if meta.Content != "" {
+		line("Content", meta.Content)
+	}
+	if meta.Compression != fortifier.CompressionNone {
+		line("Compression", meta.Compression)
+	}
+	line("Timestamp", meta.Timestamp.Format(time.RFC3339))
+	if m := meta.Sss; m != nil {
+		line("SSS Parts/Threshold", fmt.Sprintf("%d/%d", m.Parts, m.Threshold))
+		line("SSS Digest", m.Digest)
+		line("SSS Timestamp", m.Timestamp.Format(time.RFC3339))
+	}
+	if m := meta.Rsa; m != nil {
+		line("RSA Digest", m.Digest)
+		for _, r := range append([]*fortifier.MetadataRsa{m}, meta.Recipients...) {
+			if r.Fingerprint != "" {
+				line("RSA Recipient", r.Fingerprint)
+			}
+		}
+	}
+	if m := meta.Ecdh; m != nil {
+		line("ECDH Digest", m.Digest)
+		line("ECDH Curve", m.Curve)
+		line("ECDH Recipient", m.Fingerprint)
+	}
+	if m := meta.Passphrase; m != nil {
+		line("Passphrase Digest", m.Digest)
+		line("Argon2id Cost", fmt.Sprintf("time=%d memory=%dKiB threads=%d", m.Time, m.Memory, m.Threads))
+	}
+	line("Head Checksum", r.HeadChecksum)
+	line("Checksum", r.Checksum)
+	if r.HeadVerified != nil {
+		status := "invalid"
+		if *r.HeadVerified {
+			status = "verified"
+		}
+		line("Head Verification", status)
+	} else {
+		line("Head Verification", "skipped (no keys)")
+	}
+}
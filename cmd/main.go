@@ -1,9 +1,50 @@
 package cmd
 
+import (
+	"errors"
+	"os/exec"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/spf13/cobra"
+)
+
+// exitError carries the exit code of a command which fails in a way scripts tell apart
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// errProgramFailed is the error of a program which exits with a failure, as reported by a supervisor
+var errProgramFailed = errors.New("program failed")
+
+// silenceProgramExit keeps c from printing err if it only carries the exit status of a program,
+// which has reported its own errors
+func silenceProgramExit(c *cobra.Command, err error) error {
+	var exit *exec.ExitError
+	if errors.As(err, &exit) || errors.Is(err, errProgramFailed) {
+		c.SilenceErrors = true
+	}
+	return err
+}
+
 func Execute() int {
+	// The key material of descriptors and stdin serves every key a command loads, until it returns
+	defer files.ForgetSources()
 	if err := root.Execute(); err == nil {
 		return 0
 	} else {
+		var exit *exitError
+		if errors.As(err, &exit) {
+			return exit.code
+		}
 		return 1
 	}
 }
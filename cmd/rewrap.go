@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var key string
+	var to []string
+	var split bool
+	var parts, threshold uint8
+	c := &cobra.Command{
+		Short: "Wrap the secret key of the fortified file with new keys, without encrypting it again",
+		Long: `Wrap the secret key of the fortified file with new keys, without encrypting it again.
+
+Rewrapping does not revoke access: the secret key stays the same, so whoever holds the old keys,
+or a copy of the old file, or recovered the secret key once, still decrypts the data.
+To revoke access, decrypt the file and encrypt it again with 'fortify encrypt', then destroy the old copies.`,
+		Use:  "rewrap -i <input-file> [flags] <key1> [key2] ... --to <new-key1> [--to <new-key2>] ...",
+		Args: cobra.MinimumNArgs(0),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return rewrap(flagIn, key, to, split, parts, threshold, args)
+		},
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Required Arguments:
+  <key1>   Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa' or 'ecdh'
+           (no key file if cipher key kind of <input-file> is 'passphrase')
+  [key2]   [Required cipher key kind of <input-file> is 'sss'] Path to the second secret share file
+  ...      Additional paths to secret share files (all files remain unmodified)
+%s`, c.UsageTemplate(), keySourcesUsage))
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagTruncate(c)
+	initFlagVerbose(c)
+	initFlagPassphrase(c)
+	initFlagIn(c, "[Required] Path of the fortified/encrypted file, which is rewritten")
+	_ = c.MarkFlagRequired("in")
+	c.Flags().StringVarP(&key, "key", "k", "",
+		"New cipher key kind name, options: [sss|rsa|ecdh] (default the cipher key kind of <input-file>)")
+	c.Flags().StringArrayVarP(&to, "to", "", nil,
+		"Path to a public key file of a new recipient if -k/--key is 'rsa' or 'ecdh' (repeatable for 'rsa'), "+
+			"no file to split the secret key into new secret shares if -k/--key is 'sss'")
+	c.Flags().BoolVarP(&split, "sss", "", false,
+		"Also split the secret key into new secret shares if -k/--key is not 'sss'")
+	initFlagPartsAndThreshold(c, &parts, &threshold, defaultEncSssParts, defaultEncSssThreshold)
+}
+
+func rewrap(input, key string, to []string, split bool, parts, threshold uint8, args []string) (err error) {
+	if input == files.StdStream {
+		return fmt.Errorf("rewrap requires a fortified file, not %s", input)
+	}
+	files.SetVerbose(flagVerbose)
+	in, iCloseFn, err := files.OpenInputFile(input)
+	if err != nil {
+		return
+	}
+	layout := &fortifier.FileLayout{}
+	err = layout.ReadHeadIn(in)
+	iCloseFn()
+	if err != nil {
+		return
+	}
+	if flagVerbose {
+		fmt.Printf("%s\n", layout.String())
+	}
+	meta := layout.Metadata()
+	var f, next *fortifier.Fortifier
+	if f, _, err = newFortifier(meta.Key, meta, args); err != nil {
+		return
+	}
+	kind := meta.Key
+	if key != "" {
+		kind = fortifier.CipherKeyKind(key)
+	}
+	if next, _, err = newFortifier(kind, nil, to); err != nil {
+		return
+	}
+	if (kind == fortifier.CipherKeyKindSSS && len(to) == 0) || (kind != fortifier.CipherKeyKindSSS && split) {
+		next.SplitKey(parts, threshold, flagTruncate)
+	}
+	return fortifier.Rewrap(input, f, next)
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/i3ash/fortify/files"
 	"github.com/i3ash/fortify/fortifier"
@@ -21,39 +25,123 @@ func init() {
 }
 
 func newFortifier(kind fortifier.CipherKeyKind, meta *fortifier.Metadata, args []string) (*fortifier.Fortifier, []string, error) {
+	if err := checkStdinKeys(flagIn, args); err != nil {
+		return nil, args, err
+	}
+	if meta != nil && kind != fortifier.CipherKeyKindSSS && meta.Sss != nil {
+		if parts, err := sss.CombineKeyFiles(args); err == nil && len(parts) > 0 {
+			return newFortifierWithSss(parts), args[len(parts):], nil
+		}
+	}
 	switch kind {
 	case fortifier.CipherKeyKindSSS:
 		if parts, err := sss.CombineKeyFiles(args); err != nil {
 			return nil, args, err
 		} else {
-			return fortifier.NewFortifierWithSss(flagVerbose, flagTruncate, parts), args[len(parts):], nil
+			return newFortifierWithSss(parts), args[len(parts):], nil
 		}
 	case fortifier.CipherKeyKindRSA:
+		if meta == nil {
+			return newFortifierWithRsaPublicKeys(args)
+		}
 		if kb, err := readKeyFile(args); err != nil {
 			return nil, args, err
 		} else {
 			return fortifier.NewFortifierWithRsa(flagVerbose, meta, kb), args[1:], nil
 		}
+	case fortifier.CipherKeyKindPassphrase:
+		if passphrase, err := readPassphrase(); err != nil {
+			return nil, args, err
+		} else {
+			return fortifier.NewFortifierWithPassphrase(flagVerbose, meta, passphrase), args, nil
+		}
+	case fortifier.CipherKeyKindECDH:
+		if kb, err := readKeyFile(args); err != nil {
+			return nil, args, err
+		} else if meta == nil && len(args) > 1 {
+			return nil, args, fmt.Errorf("cipher key kind %s takes one public key file", kind)
+		} else {
+			return fortifier.NewFortifierWithEcdh(flagVerbose, meta, kb), args[min(1, len(args)):], nil
+		}
 	default:
 		return nil, args, fmt.Errorf("unknown cipher key kind: %s", kind)
 	}
 }
 
-func readKeyFile(args []string) (kb []byte, err error) {
-	size := len(args)
-	if size == 0 {
-		return
+// newFortifierWithSss recovers the key from parts, telling on stderr those which are ignored
+func newFortifierWithSss(parts []sss.Part) *fortifier.Fortifier {
+	f := fortifier.NewFortifierWithSss(flagVerbose, flagTruncate, parts)
+	f.OnIgnoredShares(printIgnoredShares)
+	return f
+}
+
+// printIgnoredShares tells on stderr the secret shares which the secret is recovered without
+func printIgnoredShares(ignored []*sss.ShareError) {
+	for _, e := range ignored {
+		_, _ = fmt.Fprintf(os.Stderr, "Ignore %v\n", e)
+	}
+}
+
+// checkStdinKeys returns an error if a key of args is read from stdin while input is stdin as well
+func checkStdinKeys(input string, args []string) error {
+	if strings.TrimSpace(input) != files.StdStream {
+		return nil
 	}
-	var kCloseFn func()
-	var kf *os.File
-	if kf, kCloseFn, err = files.OpenInputFile(args[0]); err != nil {
-		return
+	for _, arg := range args {
+		if strings.TrimSpace(arg) == files.StdStream {
+			return fmt.Errorf("key %s and input %s cannot both be read from stdin", arg, input)
+		}
+	}
+	return nil
+}
+
+// newFortifierWithRsaPublicKeys takes every argument as a public key file of a recipient
+func newFortifierWithRsaPublicKeys(args []string) (*fortifier.Fortifier, []string, error) {
+	if len(args) == 0 {
+		return fortifier.NewFortifierWithRsa(flagVerbose, nil, nil), args, nil
+	}
+	kbs := make([][]byte, len(args))
+	for i := range args {
+		var err error
+		if kbs[i], err = readKeyFile(args[i:]); err != nil {
+			return nil, args, err
+		}
+	}
+	return fortifier.NewFortifierWithRsa(flagVerbose, nil, kbs[0], kbs[1:]...), nil, nil
+}
+
+// readPassphrase reads the passphrase from an environment variable or the first line of a file descriptor.
+// It returns nil if neither is specified, leaving the passphrase to be entered on the terminal.
+// The passphrase is read once, so that it serves every input file of a command.
+var readPassphrase = sync.OnceValues(func() ([]byte, error) {
+	if name := strings.TrimSpace(flagPassEnv); name != "" {
+		if value, ok := os.LookupEnv(name); !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		} else {
+			return []byte(value), nil
+		}
 	}
-	defer kCloseFn()
-	if kb, err = io.ReadAll(kf); err != nil {
-		return
+	if flagPassFd < 0 {
+		return nil, nil
+	}
+	file := os.NewFile(uintptr(flagPassFd), "passphrase")
+	if file == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", flagPassFd)
+	}
+	defer func() { _ = file.Close() }()
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+})
+
+// readKeyFile reads the key material of the first of args, which is a path or another key source of files.ReadSource
+func readKeyFile(args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, nil
 	}
-	return
+	return files.ReadSource(args[0])
 }
 
 func cmdVersion() *cobra.Command {
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/i3ash/fortify/pkg/envfile"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	var envFiles []string
+	var format string
+	c := &cobra.Command{
+		Short: "Run a command with the variables of fortified environment files added to its environment",
+		Use:   "run --env-file <input-file> [flags] <key1> [key2] ... -- <command> [arg1] [arg2] ...",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			dash := c.ArgsLenAtDash()
+			if dash < 0 || dash == len(args) {
+				return errors.New("missing command after --")
+			}
+			c.SilenceUsage = true
+			return silenceProgramExit(c, runWithEnv(envFiles, envfile.Format(format), args[:dash], args[dash:]))
+		},
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Required Arguments:
+  <key1>      Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa' or 'ecdh'
+              (no key file if cipher key kind of <input-file> is 'passphrase')
+  [key2]      [Required cipher key kind of <input-file> is 'sss'] Path to the second secret share file
+  ...         Additional paths to secret share files (all files remain unmodified)
+  <command>   Command to run after --, with its arguments
+%s`, c.UsageTemplate(), keySourcesUsage))
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagVerbose(c)
+	initFlagPassphrase(c)
+	initFlagSupervise(c)
+	c.Flags().StringArrayVarP(&envFiles, "env-file", "e", nil,
+		"[Required] Path of a fortified environment file, later ones override earlier ones (repeatable)")
+	_ = c.MarkFlagRequired("env-file")
+	c.Flags().StringVarP(&format, "env-format", "", string(envfile.FormatAuto),
+		"Format of the decrypted environment files, options: [auto|dotenv|json|yaml]")
+}
+
+// runWithEnv decrypts the environment files in memory and executes command with their variables,
+// which override the variables of the current environment
+func runWithEnv(envFiles []string, format envfile.Format, keys, command []string) (err error) {
+	files.SetVerbose(flagVerbose)
+	env := os.Environ()
+	for _, name := range envFiles {
+		var vars []envfile.Variable
+		if vars, err = readEnvFile(name, format, keys); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		env = mergeEnv(env, vars)
+	}
+	// The keys of all the files are loaded, so the key material need not stay in memory for the life of the command
+	files.ForgetSources()
+	var path string
+	if path, err = exec.LookPath(command[0]); err != nil {
+		return
+	}
+	if !flagSupervise {
+		if err = syscall.Exec(path, command, env); err == nil {
+			return nil
+		}
+	}
+	return run(path, command, env, nil, nil)
+}
+
+func readEnvFile(name string, format envfile.Format, keys []string) (vars []envfile.Variable, err error) {
+	var in *os.File
+	var iCloseFn func()
+	if in, iCloseFn, err = files.OpenInputFile(name); err != nil {
+		return
+	}
+	defer iCloseFn()
+	var layout *fortifier.FileLayout
+	var dec fortifier.Decrypter
+	if layout, dec, err = newDecrypter(in, keys); err != nil {
+		return
+	}
+	if layout.Metadata().Content == fortifier.ContentKindTar {
+		return nil, errors.New("a fortified directory tree is not an environment file")
+	}
+	var buf bytes.Buffer
+	defer func() {
+		// Wipe the decrypted secrets once they are parsed
+		b := buf.Bytes()
+		clear(b[:cap(b)])
+	}()
+	if err = dec.Decrypt(in, &buf, layout); err != nil {
+		return
+	}
+	return envfile.Parse(buf.Bytes(), format)
+}
+
+// mergeEnv sets vars in env, in place of the variables of the same names
+func mergeEnv(env []string, vars []envfile.Variable) []string {
+	index := make(map[string]int, len(env))
+	for i, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		index[name] = i
+	}
+	for _, v := range vars {
+		kv := v.Name + "=" + v.Value
+		if i, ok := index[v.Name]; ok {
+			env[i] = kv
+		} else {
+			index[v.Name] = len(env)
+			env = append(env, kv)
+		}
+	}
+	return env
+}
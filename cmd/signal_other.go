@@ -0,0 +1,19 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+)
+
+// notifySignals relays to c the signals to be forwarded to the program
+func notifySignals(c chan<- os.Signal) {
+	signal.Notify(c)
+}
+
+// forwardedSignal reports whether sig is forwarded to the program, which is the case for any signal
+// that reaches fortify without unix signals
+func forwardedSignal(os.Signal, bool) bool {
+	return true
+}
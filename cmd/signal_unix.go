@@ -0,0 +1,49 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// notifySignals relays to c the signals to be forwarded to the program, but the job control ones,
+// which keep stopping fortify itself when it reads or writes the terminal in the background
+func notifySignals(c chan<- os.Signal) {
+	signal.Notify(c)
+	signal.Reset(syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU)
+}
+
+// forwardedSignal reports whether sig is forwarded to the program, which is not the case for the signals
+// about fortify itself: its exited children, its broken pipes and the preemption of the Go runtime.
+// Nor is it the case for the signals of the terminal if the program is in the process group of fortify
+// and that group is in the foreground of the terminal, as the terminal has already sent them to the whole group.
+// Sent by kill, a service manager or a container runtime otherwise, they are forwarded like any other signal.
+func forwardedSignal(sig os.Signal, sameGroup bool) bool {
+	switch sig {
+	case syscall.SIGCHLD, syscall.SIGPIPE, syscall.SIGURG:
+		return false
+	case syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP, syscall.SIGWINCH:
+		return !sameGroup || !foregroundGroup()
+	default:
+		return true
+	}
+}
+
+// foregroundGroup reports whether the process group of fortify is the foreground group of its controlling terminal
+func foregroundGroup() bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer func() { _ = tty.Close() }()
+	foreground, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	if err != nil {
+		return false
+	}
+	pgid, err := unix.Getpgid(0)
+	return err == nil && pgid == foreground
+}
@@ -0,0 +1,72 @@
+//go:build unix
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestForwardedSignal(t *testing.T) {
+	// The signals of the terminal are forwarded to a program in the same group unless the terminal sent them
+	fromKill := !foregroundGroup()
+	for _, c := range []struct {
+		sig       os.Signal
+		sameGroup bool
+		forwarded bool
+	}{
+		{syscall.SIGTERM, true, true},
+		{syscall.SIGHUP, true, true},
+		{syscall.SIGUSR1, false, true},
+		{syscall.SIGINT, true, fromKill},
+		{syscall.SIGINT, false, true},
+		{syscall.SIGWINCH, true, fromKill},
+		{syscall.SIGWINCH, false, true},
+		{syscall.SIGQUIT, true, fromKill},
+		{syscall.SIGCHLD, false, false},
+		{syscall.SIGURG, false, false},
+	} {
+		if got := forwardedSignal(c.sig, c.sameGroup); got != c.forwarded {
+			t.Errorf("%v in the same group %v: expected forwarded %v, got %v", c.sig, c.sameGroup, c.forwarded, got)
+		}
+	}
+}
+
+func TestRun_ForwardsInterrupt(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	if foregroundGroup() {
+		t.Skip("SIGINT of the terminal is not forwarded")
+	}
+	ready := filepath.Join(t.TempDir(), "ready")
+	script := fmt.Sprintf("trap 'exit 7' INT; touch %s; while :; do sleep 0.05; done", ready)
+	done := make(chan error, 1)
+	go func() { done <- run("sh", []string{"sh", "-c", script}, nil, nil, nil) }()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(ready); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("program did not start")
+		}
+	}
+	// As sent by kill, a service manager or a container runtime
+	if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		var exit *exitError
+		if !errors.As(err, &exit) || exit.code != 7 {
+			t.Errorf("expected exit code 7, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGINT was not forwarded to the program")
+	}
+}
@@ -1,35 +1,51 @@
 package cmd
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
 	"github.com/i3ash/fortify/files"
 	"github.com/i3ash/fortify/sss"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var flagSssCombineOut string
+var flagSssCombineMnemonic bool
 
 func init() {
 	c := &cobra.Command{
 		RunE:  sssCombineRunE,
 		Use:   "combine -o <output-file> [flags] <input-file1> <input-file2> ...",
 		Short: "Combine secret shares to recover the original data",
-		Args:  cobra.MinimumNArgs(2),
+		Args: func(c *cobra.Command, args []string) error {
+			if flagSssCombineMnemonic {
+				return nil
+			}
+			return cobra.MinimumNArgs(2)(c, args)
+		},
 	}
 	c.SetUsageTemplate(fmt.Sprintf(`%s
 Required Arguments:
   <input-file1>      Path to the first secret share file
   <input-file2>      Path to the second secret share file
   ...                Additional paths to secret share files (at least two required; all files remain unmodified)
+
+Secret share files are read in any encoding of 'sss split', QR code images included.
+Given more input files than the threshold, those which disagree with the secret recovered from the others are ignored.
+With --mnemonic the input files hold the words of mnemonic shares, which are prompted for if there are none.
 `, c.UsageTemplate()))
 	initFlagHelp(c)
 	initFlagTruncate(c)
 	initFlagVerbose(c)
 	c.Flags().StringVarP(&flagSssCombineOut, "out", "o", "",
 		"[Required] Specify the output file for the recovered original data")
+	c.Flags().BoolVarP(&flagSssCombineMnemonic, "mnemonic", "", false,
+		"Combine mnemonic shares, read from the input files or prompted for on stdin")
 	ssss.AddCommand(c)
 }
 
@@ -39,5 +55,95 @@ func sssCombineRunE(_ *cobra.Command, args []string) error {
 	if len(file) == 0 {
 		return errors.New("empty path of the output file")
 	}
-	return sss.CombinePartFiles(args, file, flagTruncate, flagVerbose)
+	if flagSssCombineMnemonic {
+		return combineMnemonics(args, file)
+	}
+	ignored, err := sss.CombinePartFiles(args, file, flagTruncate, flagVerbose)
+	printIgnoredShares(ignored)
+	return err
+}
+
+// combineMnemonics recovers the secret from the mnemonic shares of the input files, or prompted for on stdin,
+// and writes it to the output file
+func combineMnemonics(inputs []string, out string) (err error) {
+	var ms []*sss.Mnemonic
+	if len(inputs) > 0 {
+		ms, err = readMnemonics(inputs)
+	} else {
+		ms, err = promptMnemonics(os.Stdin, os.Stderr, term.IsTerminal(int(os.Stdin.Fd())))
+	}
+	if err != nil {
+		return
+	}
+	var secret []byte
+	if secret, err = sss.CombineMnemonics(ms); err != nil {
+		return
+	}
+	defer clear(secret)
+	output, oCloseFn, err := files.OpenOutputFile(out, flagTruncate)
+	if err != nil {
+		return
+	}
+	defer oCloseFn()
+	_, err = output.Write(secret)
+	return
+}
+
+func readMnemonics(inputs []string) ([]*sss.Mnemonic, error) {
+	ms := make([]*sss.Mnemonic, len(inputs))
+	for i, name := range inputs {
+		b, err := files.ReadSource(name)
+		if err != nil {
+			return nil, err
+		}
+		ms[i], err = sss.ParseMnemonic(string(b))
+		clear(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return ms, nil
+}
+
+// promptMnemonics prompts for the words of mnemonic shares until there are as many as the threshold.
+// A share in error is prompted for again if retry, which is the case on a terminal.
+func promptMnemonics(in io.Reader, prompt io.Writer, retry bool) ([]*sss.Mnemonic, error) {
+	scanner := bufio.NewScanner(in)
+	var ms []*sss.Mnemonic
+	for len(ms) == 0 || len(ms) < int(ms[0].Threshold) {
+		if len(ms) == 0 {
+			_, _ = fmt.Fprintf(prompt, "Enter the words of share 1, then an empty line:\n")
+		} else {
+			_, _ = fmt.Fprintf(prompt, "Enter the words of share %d of %d, then an empty line:\n", len(ms)+1, ms[0].Threshold)
+		}
+		var text strings.Builder
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" && text.Len() > 0 {
+				break
+			}
+			text.WriteString(line + "\n")
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text.String()) == "" {
+			return nil, fmt.Errorf("input ended after %d shares", len(ms))
+		}
+		m, err := sss.ParseMnemonic(text.String())
+		for _, prev := range ms {
+			if err == nil {
+				err = m.Compatible(prev)
+			}
+		}
+		if err != nil {
+			if !retry {
+				return nil, fmt.Errorf("share %d: %w", len(ms)+1, err)
+			}
+			_, _ = fmt.Fprintf(prompt, "Invalid share: %v\n", err)
+			continue
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
 }
@@ -19,14 +19,20 @@ func init() {
 	initFlagHelp(c)
 	initFlagTruncate(c)
 	initFlagVerbose(c)
-	initFlagPartsAndThreshold(c)
+	initFlagPartsAndThreshold(c, &flagSssParts, &flagSssThreshold, defaultSssParts, defaultSssThreshold)
 	initFlagPrefix(c, "File path prefix for the generated secret shares")
+	initFlagEncoding(c)
+	initFlagVerifiable(c)
 	initFlagBytes(c, defaultRandomBytes, "Length of the randomly generated byte array")
 }
 
 func sssRandomRunE(_ *cobra.Command, _ []string) (err error) {
 	defer sss.CloseAllFilesForWrite()
 	files.SetVerbose(flagVerbose)
+	var encoding sss.Encoding
+	if encoding, err = sss.ParseEncoding(flagEncoding); err != nil {
+		return
+	}
 	var bs = uint16(flagBytes)
 	if bs == 0 || int(bs) != flagBytes {
 		return fmt.Errorf("value of flag (--bytes / -b) is out of range (0,65535]: %d", flagBytes)
@@ -35,9 +41,16 @@ func sssRandomRunE(_ *cobra.Command, _ []string) (err error) {
 	if _, err = rand.Reader.Read(secret); err != nil {
 		return
 	}
+	split := sss.Split
+	if flagVerifiable {
+		if encoding == sss.EncodingMnemonic {
+			return sss.ErrMnemonicVerifiable
+		}
+		split = sss.SplitVerifiable
+	}
 	var ps []sss.Part
-	if ps, err = sss.Split(secret, flagSssParts, flagSssThreshold); err != nil {
+	if ps, err = split(secret, flagSssParts, flagSssThreshold); err != nil {
 		return
 	}
-	return sss.AppendParts(ps, 0, 1, flagPrefix, flagTruncate)
+	return sss.AppendParts(ps, 0, 1, flagPrefix, encoding, flagTruncate)
 }
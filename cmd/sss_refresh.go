@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/sss"
+	"github.com/spf13/cobra"
+)
+
+var flagSssApply []string
+
+func init() {
+	c := &cobra.Command{
+		RunE:  sssRefreshRunE,
+		Use:   "refresh -P <prefix> [flags] <input-file>",
+		Short: "Refresh secret shares into a new set which recovers the same secret",
+		Long: `Refresh secret shares into a new set which recovers the same secret, in two steps which never bring the shares together.
+
+First, at least a threshold of holders each refresh their own share file to write an update file for every part of prefix,
+which records nothing of the secret. Each update file goes to the holder of its part only, as secretly as the share itself.
+Then each holder refreshes their own share file with --apply for the update files of the same holders as every other
+holder, at least a threshold of them, to a share file of prefix, which adds up the updates. The refreshed shares record
+these holders, and shares refreshed by the updates of different holders do not combine.
+
+Whoever runs a refresh, and whoever sees an update file, can turn an old share of the part into a new one together with
+every other update of that part. Refresh only on trusted machines, and destroy the update files once they are applied.
+
+The refreshed shares are of the next generation, which do not combine with the shares of the old set.
+A verifiable share is verified against the commitments of every update, which must keep the secret.`,
+		Args: cobra.ExactArgs(1),
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Required Arguments:
+  <input-file>       Path to a secret share file (remains unmodified)
+`, c.UsageTemplate()))
+	ssss.AddCommand(c)
+	initFlagHelp(c)
+	initFlagVerbose(c)
+	initFlagTruncate(c)
+	initFlagPrefix(c, "File path prefix for the update files, or with --apply for the refreshed secret share")
+	initFlagEncoding(c)
+	c.Flags().StringArrayVarP(&flagSssApply, "apply", "", nil,
+		"Update file to apply to the secret share of the input file, repeated for the update of each holder, the same holders for every part")
+}
+
+func sssRefreshRunE(_ *cobra.Command, args []string) error {
+	defer sss.CloseAllFilesForWrite()
+	files.SetVerbose(flagVerbose)
+	if len(flagSssApply) == 0 {
+		return sss.WriteUpdateFiles(args[0], flagPrefix, flagTruncate, flagVerbose)
+	}
+	encoding, err := sss.ParseEncoding(flagEncoding)
+	if err != nil {
+		return err
+	}
+	return sss.ApplyUpdateFiles(args[0], flagSssApply, flagPrefix, encoding, flagTruncate, flagVerbose)
+}
@@ -25,9 +25,11 @@ Arguments:
 	initFlagHelp(c)
 	initFlagVerbose(c)
 	initFlagTruncate(c)
-	initFlagPartsAndThreshold(c)
+	initFlagPartsAndThreshold(c, &flagSssParts, &flagSssThreshold, defaultSssParts, defaultSssThreshold)
 	initFlagIn(c, "[Required if no [input-file]] Path of the input file")
 	initFlagPrefix(c, "File path prefix for the generated secret shares")
+	initFlagEncoding(c)
+	initFlagVerifiable(c)
 }
 
 func sssSplitRunE(_ *cobra.Command, args []string) error {
@@ -40,5 +42,9 @@ func sssSplitRunE(_ *cobra.Command, args []string) error {
 	if len(file) == 0 {
 		return errors.New("empty path of the input file")
 	}
-	return sss.SplitIntoFiles(file, flagSssParts, flagSssThreshold, flagPrefix, flagTruncate, flagVerbose)
+	encoding, err := sss.ParseEncoding(flagEncoding)
+	if err != nil {
+		return err
+	}
+	return sss.SplitIntoFiles(file, flagSssParts, flagSssThreshold, flagVerifiable, flagPrefix, encoding, flagTruncate, flagVerbose)
 }
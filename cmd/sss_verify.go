@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/i3ash/fortify/sss"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	c := &cobra.Command{
+		Short: "Verify secret share files against the commitments they carry",
+		Long: `Verify secret share files against the commitments they carry.
+
+A verifiable secret share, split with --verifiable, carries the Pedersen commitments to the polynomials of all shares
+of its secret, so that its holder checks it without the other shares. Other shares cannot be verified.`,
+		Use:          "verify <input-file1> [input-file2] ...",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return sssVerify(os.Stdout, args)
+		},
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Required Arguments:
+  <input-file1>   Path to a secret share file in any encoding but mnemonics
+  ...             Additional paths to secret share files (all files remain unmodified)
+`, c.UsageTemplate()))
+	ssss.AddCommand(c)
+	initFlagHelp(c)
+}
+
+func sssVerify(w io.Writer, inputs []string) error {
+	failed := 0
+	for _, input := range inputs {
+		blocks, err := sss.VerifyPartFile(input)
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintf(w, "%s: FAILED, %v\n", input, err)
+			continue
+		}
+		if blocks == 1 {
+			_, _ = fmt.Fprintf(w, "%s: ok\n", input)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s: ok, %d blocks verified\n", input, blocks)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d secret share files failed verification", failed, len(inputs))
+	}
+	return nil
+}
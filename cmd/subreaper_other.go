@@ -0,0 +1,9 @@
+//go:build !linux
+
+package cmd
+
+import "errors"
+
+func setChildSubreaper() error {
+	return errors.New("child subreaper is only supported on linux")
+}
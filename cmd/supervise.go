@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultGracePeriod is the time the rest of the process group has to exit after SIGTERM before it is killed
+const defaultGracePeriod = 10 * time.Second
+
+var flagSupervise bool
+var flagGracePeriod = defaultGracePeriod
+
+func initFlagSupervise(c *cobra.Command) {
+	c.Flags().BoolVarP(&flagSupervise, "supervise", "", false,
+		"Run the program as a child in its own process group, reap orphaned processes and kill the group on shutdown (e.g. as PID 1 of a container)")
+	c.Flags().DurationVarP(&flagGracePeriod, "grace-period", "", defaultGracePeriod,
+		"Time the process group has to exit after SIGTERM on shutdown before it is killed in supervisor mode")
+}
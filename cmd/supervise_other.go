@@ -0,0 +1,12 @@
+//go:build !unix
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+func supervise(string, []string, []string, *os.File, *os.File) error {
+	return errors.New("supervisor mode is only supported on unix")
+}
@@ -0,0 +1,112 @@
+//go:build unix
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// supervisePollInterval is how often a supervisor shutting down checks whether the process group is gone,
+// as the exits of the group members which are not children of fortify raise no SIGCHLD
+const supervisePollInterval = 100 * time.Millisecond
+
+// supervise runs the program at path with argv like run does, but in its own process group with fortify as a child subreaper.
+// The signals are forwarded to the whole group and every exited child is reaped, including the orphaned descendants.
+// Once the program exits, or fortify is asked to terminate, the group gets SIGTERM and is killed after the grace period.
+func supervise(path string, argv, env []string, stdin, out *os.File) error {
+	signals := make(chan os.Signal, 16)
+	notifySignals(signals)
+	defer signal.Stop(signals)
+	if err := setChildSubreaper(); err != nil && flagVerbose {
+		_, _ = fmt.Fprintf(os.Stderr, "Failed to become child subreaper: %v\n", err)
+	}
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if stdin == nil && term.IsTerminal(int(os.Stdin.Fd())) {
+		// The group of the program takes over the terminal, so that it keeps reading it
+		attr.Foreground = true
+		attr.Ctty = int(os.Stdin.Fd())
+	}
+	cmd, err := start(path, argv, env, stdin, out, attr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cmd.Process.Release() }()
+	pid := cmd.Process.Pid
+	var status syscall.WaitStatus
+	var exited, killed bool
+	var deadline, poll <-chan time.Time
+	shutdown := func() {
+		if deadline == nil {
+			deadline = time.After(flagGracePeriod)
+			poll = time.Tick(supervisePollInterval)
+		}
+	}
+	for {
+		if reapChildren(pid, &status) && !exited {
+			exited = true
+			_ = syscall.Kill(-pid, syscall.SIGTERM)
+			shutdown()
+		}
+		if exited && syscall.Kill(-pid, 0) != nil {
+			return waitStatusError(status)
+		}
+		select {
+		case sig := <-signals:
+			switch sig {
+			case syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT:
+				_ = syscall.Kill(-pid, sig.(syscall.Signal))
+				shutdown()
+			default:
+				if s, ok := sig.(syscall.Signal); ok && forwardedSignal(sig, false) {
+					_ = syscall.Kill(-pid, s)
+				}
+			}
+		case <-deadline:
+			if !killed {
+				killed = true
+				_ = syscall.Kill(-pid, syscall.SIGKILL)
+			}
+		case <-poll:
+		}
+	}
+}
+
+// reapChildren reaps every exited child of fortify, and reports whether the program of pid is one of them
+// with its status
+func reapChildren(pid int, status *syscall.WaitStatus) (reaped bool) {
+	for {
+		var ws syscall.WaitStatus
+		p, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || p <= 0 {
+			return
+		}
+		if p == pid {
+			*status = ws
+			reaped = true
+		}
+	}
+}
+
+// waitStatusError returns an exitError with the exit code of the program which ended with status,
+// or 128 plus the number of the signal which killed it, like programExitError does
+func waitStatusError(status syscall.WaitStatus) error {
+	switch {
+	case status.Signaled():
+		return &exitError{code: 128 + int(status.Signal()),
+			err: fmt.Errorf("%w: signal: %v", errProgramFailed, status.Signal())}
+	case status.ExitStatus() != 0:
+		return &exitError{code: status.ExitStatus(),
+			err: fmt.Errorf("%w: exit status %d", errProgramFailed, status.ExitStatus())}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,100 @@
+//go:build unix
+
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/i3ash/fortify/pkg/envfile"
+)
+
+func TestSupervise_KillsGroup(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	flagSupervise, flagGracePeriod = true, 200*time.Millisecond
+	defer func() { flagSupervise, flagGracePeriod = false, defaultGracePeriod }()
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	// The orphan ignores SIGTERM, so that only SIGKILL after the grace period ends it
+	script := fmt.Sprintf("(trap '' TERM; sleep 30) & echo $! > %s; exit 3", pidFile)
+	err := run("sh", []string{"sh", "-c", script}, nil, nil, nil)
+	var exit *exitError
+	if !errors.As(err, &exit) || exit.code != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	if pid <= 0 {
+		t.Fatalf("invalid pid %q", data)
+	}
+	if err = syscall.Kill(pid, 0); err == nil {
+		t.Errorf("orphan %d is still running", pid)
+	}
+}
+
+func TestRunWithEnv_FdKeyForEveryFile(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	flagSupervise = true
+	defer func() { flagSupervise = false }()
+	dir := t.TempDir()
+	first, priPath := fortifyWithRsa(t, dir, []byte("A=1\n"))
+	// The second file has a data key of its own for the same private key
+	kb, _ := os.ReadFile(priPath)
+	blk, _ := pem.Decode(kb)
+	pri, err := x509.ParsePKCS1PrivateKey(blk.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&pri.PublicKey)
+	pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	_ = os.WriteFile(filepath.Join(dir, "second.txt"), []byte("B=2\n"), 0644)
+	second := filepath.Join(dir, "second.data")
+	fi, _ := os.Open(filepath.Join(dir, "second.txt"))
+	fo, _ := os.Create(second)
+	f := fortifier.NewFortifierWithRsa(false, nil, pub)
+	err = fortifier.NewEncrypter(fortifier.CipherModeAes256CTR, f).EncryptFile(fi, fo)
+	_, _ = fi.Close(), fo.Close()
+	if err != nil {
+		t.Fatalf("EncryptFile failed: %v", err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	go func() {
+		_, _ = w.Write(kb)
+		_ = w.Close()
+	}()
+	fd, err := syscall.Dup(int(r.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer files.ForgetSources()
+	// The descriptor is closed once read, and the second file may be opened on it
+	err = runWithEnv([]string{first, second}, envfile.FormatDotenv, []string{"fd:" + strconv.Itoa(fd)},
+		[]string{"sh", "-c", `test "$A" = 1 && test "$B" = 2`})
+	if err != nil {
+		t.Errorf("runWithEnv failed: %v", err)
+	}
+}
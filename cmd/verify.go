@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/fortifier"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of verify, the highest one of all input files wins
+const (
+	exitCodeVerifyError    = 1 // reading or other errors
+	exitCodeVerifyNotFile  = 2 // not a fortified file
+	exitCodeVerifyKey      = 3 // the keys cannot unlock the secret key
+	exitCodeVerifyHead     = 4 // invalid head checksum
+	exitCodeVerifyLength   = 5 // truncated or extended data
+	exitCodeVerifyChecksum = 6 // invalid checksum of the data
+)
+
+const (
+	verifyStatusOk      = "ok"
+	verifyStatusFailed  = "FAILED"
+	verifyStatusSkipped = "-"
+)
+
+type verifyResult struct {
+	file     string
+	head     string
+	length   string
+	checksum string
+	code     int
+	err      error
+}
+
+func init() {
+	var inputs []string
+	c := &cobra.Command{
+		Short: "Verify the checksums of fortified files without writing the decrypted data",
+		Long: `Verify the checksums of fortified files without writing the decrypted data.
+
+Exit codes, the highest one of all input files wins:
+  0  All input files are valid
+  1  Reading an input file or setting up the keys failed
+  2  An input file is not a fortified file
+  3  The keys cannot unlock an input file
+  4  The head checksum of an input file is invalid
+  5  The data of an input file is truncated or extended
+  6  The checksum of the data of an input file is invalid`,
+		Use:          "verify -i <input-file> [-i <input-file>] ... [flags] <key1> [key2] ...",
+		Args:         cobra.MinimumNArgs(0),
+		SilenceUsage: true,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return verify(os.Stdout, inputs, args)
+		},
+	}
+	c.SetUsageTemplate(fmt.Sprintf(`%s
+Required Arguments:
+  <key1>   Path to the first secret share file or private key file if cipher key kind of <input-file> is 'rsa' or 'ecdh'
+           (no key file if cipher key kind of <input-file> is 'passphrase')
+  [key2]   [Required cipher key kind of <input-file> is 'sss'] Path to the second secret share file
+  ...      Additional paths to secret share files (all files remain unmodified)
+%s`, c.UsageTemplate(), keySourcesUsage))
+	root.AddCommand(c)
+	initFlagHelp(c)
+	initFlagPassphrase(c)
+	c.Flags().StringArrayVarP(&inputs, "in", "i", nil,
+		"[Required] Path of a fortified/encrypted input file, or '-' for stdin (repeatable)")
+	_ = c.MarkFlagRequired("in")
+}
+
+func verify(w io.Writer, inputs, args []string) error {
+	code, failed := 0, 0
+	var last error
+	// Files sharing a key unlock it once, which also reads the descriptors and stdin only once
+	keys := &batchKeys{keys: make(map[string]*fortifier.Fortifier), args: args}
+	for _, input := range inputs {
+		r := verifyFile(input, keys)
+		_, _ = fmt.Fprintf(w, "%s: head %s, length %s, checksum %s", r.file, r.head, r.length, r.checksum)
+		if r.err != nil {
+			_, _ = fmt.Fprintf(w, " (%v)", r.err)
+			failed++
+			last = r.err
+			code = max(code, r.code)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	if failed == 0 {
+		return nil
+	}
+	if failed > 1 {
+		last = fmt.Errorf("%d of %d files failed verification", failed, len(inputs))
+	}
+	return &exitError{code: code, err: last}
+}
+
+func verifyFile(input string, keys *batchKeys) (r *verifyResult) {
+	r = &verifyResult{file: input, head: verifyStatusSkipped, length: verifyStatusSkipped,
+		checksum: verifyStatusSkipped, code: exitCodeVerifyError}
+	in, iCloseFn, err := files.OpenInputFile(input)
+	if err != nil {
+		r.err = err
+		return
+	}
+	defer iCloseFn()
+	layout := &fortifier.FileLayout{}
+	if r.err = layout.ReadHeadIn(in); r.err != nil {
+		r.code = exitCodeVerifyNotFile
+		return
+	}
+	meta := layout.Metadata()
+	var f *fortifier.Fortifier
+	if f, r.err = keys.fortifierOf(meta); r.err != nil {
+		r.code = exitCodeVerifyKey
+		return
+	}
+	var dec fortifier.Decrypter
+	if dec = fortifier.NewDecrypter(meta.Mode, f); dec == nil {
+		r.err = fmt.Errorf("unknown cipher mode name: %s", meta.Mode)
+		return
+	}
+	r.err = dec.Decrypt(in, nil, layout)
+	switch {
+	case r.err == nil:
+		r.head, r.length, r.checksum = verifyStatusOk, verifyStatusOk, verifyStatusOk
+		r.code = 0
+	case errors.Is(r.err, fortifier.ErrKeyDigest):
+		r.code = exitCodeVerifyKey
+	case errors.Is(r.err, fortifier.ErrInvalidHeadChecksum):
+		r.head = verifyStatusFailed
+		r.code = exitCodeVerifyHead
+	case errors.Is(r.err, fortifier.ErrDataLength):
+		r.head, r.length = verifyStatusOk, verifyStatusFailed
+		r.code = exitCodeVerifyLength
+	case errors.Is(r.err, fortifier.ErrTruncatedChunks), errors.Is(r.err, io.ErrUnexpectedEOF),
+		errors.Is(r.err, io.EOF):
+		r.length = verifyStatusFailed
+		r.code = exitCodeVerifyLength
+	case errors.Is(r.err, fortifier.ErrInvalidChecksum):
+		if r.err == fortifier.ErrInvalidChecksum {
+			// the data was read through, after the head and the data length were verified
+			r.head, r.length = verifyStatusOk, verifyStatusOk
+		}
+		r.checksum = verifyStatusFailed
+		r.code = exitCodeVerifyChecksum
+	}
+	if r.code > exitCodeVerifyHead && !layout.HasTrailer() {
+		r.head = verifyStatusOk
+	}
+	return
+}
@@ -0,0 +1,298 @@
+package files
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// WriteArchive writes the directory tree at dir to w as a tar archive, with paths relative to dir.
+// Modes, modification times and symbolic links are kept as they are, other special files are skipped,
+// and so are the files of exclude, such as the file which the archive is written to.
+func WriteArchive(w io.Writer, dir string, exclude ...os.FileInfo) (err error) {
+	var stat os.FileInfo
+	if stat, err = os.Stat(dir); err != nil {
+		return
+	}
+	if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	tw := tar.NewWriter(w)
+	err = filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(exclude, func(fi os.FileInfo) bool { return os.SameFile(fi, info) }) {
+			if verbose {
+				fmt.Printf("%s --> skip (excluded)\n", name)
+			}
+			return nil
+		}
+		var link string
+		switch mode := info.Mode(); {
+		case mode&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(name); err != nil {
+				return err
+			}
+		case mode.IsDir(), mode.IsRegular():
+		default:
+			if verbose {
+				fmt.Printf("%s --> skip (%s)\n", name, mode.Type())
+			}
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		hdr.Uname, hdr.Gname = "", ""
+		hdr.Format = tar.FormatPAX
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Printf("%s --> pack\n", name)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFileTo(tw, name)
+	})
+	if err != nil {
+		return
+	}
+	return tw.Close()
+}
+
+func copyFileTo(w io.Writer, name string) (err error) {
+	var file *os.File
+	if file, err = os.Open(name); err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+	_, err = io.Copy(w, file)
+	return
+}
+
+type archivedDir struct {
+	name  string
+	mode  fs.FileMode
+	mtime time.Time
+}
+
+// ExtractArchive extracts the tar archive read from r into the directory dir, which is created if missing.
+// Files are created only inside dir: entries with absolute paths or paths out of dir, entries under
+// symbolic links of the archive, symbolic links pointing out of dir, and entries other than directories,
+// regular files and symbolic links are rejected.
+// Existing files are kept unless truncate is true.
+func ExtractArchive(r io.Reader, dir string, truncate bool) (err error) {
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	var root *os.Root
+	if root, err = os.OpenRoot(dir); err != nil {
+		return
+	}
+	defer func() { _ = root.Close() }()
+	var dirs []archivedDir
+	if dirs, err = extractArchive(r, root, dir, truncate); err != nil {
+		return
+	}
+	return restoreDirs(root, dirs)
+}
+
+// ExtractArchiveVerified extracts the tar archive read from r as ExtractArchive does, but into a staging directory
+// inside dir, and moves the entries into dir only once verify returns nil after the archive is read.
+// Otherwise nothing is left in dir, which is removed as well if it is created.
+// Existing files are kept unless truncate is true, and then nothing is moved.
+func ExtractArchiveVerified(r io.Reader, dir string, truncate bool, verify func() error) (err error) {
+	_, statErr := os.Stat(dir)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	var root *os.Root
+	if root, err = os.OpenRoot(dir); err != nil {
+		return
+	}
+	defer func() { _ = root.Close() }()
+	var staging string
+	if staging, err = os.MkdirTemp(dir, ".fortify-extract-"); err != nil {
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(staging)
+		if err != nil && errors.Is(statErr, fs.ErrNotExist) {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+	staged := filepath.Base(staging)
+	var stagingRoot *os.Root
+	if stagingRoot, err = root.OpenRoot(staged); err != nil {
+		return
+	}
+	// The directories keep their modes until they are moved, so that their entries can be moved or removed
+	dirs, err := extractArchive(r, stagingRoot, dir, false)
+	_ = stagingRoot.Close()
+	if err != nil {
+		return
+	}
+	if err = verify(); err != nil {
+		return
+	}
+	if err = moveTree(root, staged, ".", truncate, true); err != nil {
+		return
+	}
+	if err = moveTree(root, staged, ".", truncate, false); err != nil {
+		return
+	}
+	return restoreDirs(root, dirs)
+}
+
+// extractArchive extracts the tar archive read from r into root, which is the directory dir, and returns
+// the directories extracted, whose modes and modification times are left to restoreDirs
+func extractArchive(r io.Reader, root *os.Root, dir string, truncate bool) (dirs []archivedDir, err error) {
+	links := make(map[string]bool)
+	tr := tar.NewReader(r)
+	for {
+		var hdr *tar.Header
+		if hdr, err = tr.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return dirs, nil
+			}
+			return
+		}
+		name := filepath.FromSlash(path.Clean(hdr.Name))
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("%s: path out of %s", hdr.Name, dir)
+		}
+		for parent := filepath.Dir(name); parent != "."; parent = filepath.Dir(parent) {
+			if links[parent] {
+				return nil, fmt.Errorf("%s: path through symbolic link %s", hdr.Name, filepath.ToSlash(parent))
+			}
+		}
+		mode := hdr.FileInfo().Mode().Perm()
+		if parent := filepath.Dir(name); parent != "." {
+			if err = root.MkdirAll(parent, 0700); err != nil {
+				return
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = root.MkdirAll(name, 0700); err != nil {
+				return
+			}
+			dirs = append(dirs, archivedDir{name: name, mode: mode, mtime: hdr.ModTime})
+		case tar.TypeReg:
+			if err = extractFile(root, name, mode, tr, truncate); err != nil {
+				return
+			}
+			if err = root.Chtimes(name, hdr.ModTime, hdr.ModTime); err != nil {
+				return
+			}
+		case tar.TypeSymlink:
+			link := filepath.FromSlash(hdr.Linkname)
+			if path.IsAbs(hdr.Linkname) || filepath.IsAbs(link) || filepath.VolumeName(link) != "" ||
+				!filepath.IsLocal(filepath.Join(filepath.Dir(name), link)) {
+				return nil, fmt.Errorf("%s: symbolic link to %s out of %s", hdr.Name, hdr.Linkname, dir)
+			}
+			if truncate {
+				if err = root.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return
+				}
+			}
+			if err = root.Symlink(hdr.Linkname, name); err != nil {
+				return
+			}
+			links[name] = true
+		default:
+			return nil, fmt.Errorf("%s: unsupported type of entry %q", hdr.Name, hdr.Typeflag)
+		}
+		if verbose {
+			fmt.Printf("%s <-- extract\n", filepath.Join(dir, name))
+		}
+	}
+}
+
+// restoreDirs sets the modes and modification times of the directories extracted into root,
+// once nothing more is written in them
+func restoreDirs(root *os.Root, dirs []archivedDir) (err error) {
+	for _, d := range slices.Backward(dirs) {
+		if err = root.Chmod(d.name, d.mode); err != nil {
+			return
+		}
+		if err = root.Chtimes(d.name, d.mtime, d.mtime); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// moveTree moves the entries of the directory src into the directory dst, both in root, merging the directories
+// which exist in both. Existing files are replaced only if truncate is true. With check, it only returns the error
+// the move would meet, so that nothing is moved unless everything can be.
+func moveTree(root *os.Root, src, dst string, truncate, check bool) error {
+	entries, err := fs.ReadDir(root.FS(), filepath.ToSlash(src))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		from, to := filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())
+		stat, err := root.Lstat(to)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			if err = nil; !check {
+				err = root.Rename(from, to)
+			}
+		case err != nil:
+		case e.IsDir() && stat.IsDir():
+			err = moveTree(root, from, to, truncate, check)
+		case !truncate || e.IsDir() || stat.IsDir():
+			err = &fs.PathError{Op: "extract", Path: to, Err: fs.ErrExist}
+		case !check:
+			err = root.Rename(from, to)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFile(root *os.Root, name string, mode fs.FileMode, r io.Reader, truncate bool) (err error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if truncate {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	var file *os.File
+	if file, err = root.OpenFile(name, flag, 0600); err != nil {
+		return
+	}
+	defer func() {
+		if e := file.Close(); err == nil {
+			err = e
+		}
+	}()
+	if _, err = io.Copy(file, r); err != nil {
+		return
+	}
+	return file.Chmod(mode)
+}
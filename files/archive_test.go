@@ -0,0 +1,156 @@
+package files
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchive_RoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub", "deep"), 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0640); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "deep", "run.sh"), []byte("#!/bin/sh\n"), 0750); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("sub", "deep", "run.sh"), filepath.Join(src, "link")); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+	for _, name := range []string{"a.txt", "sub"} {
+		if err := os.Chtimes(filepath.Join(src, name), mtime, mtime); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, src); err != nil {
+		t.Fatalf("WriteArchive failed: %v", err)
+	}
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := ExtractArchive(bytes.NewReader(buf.Bytes()), dst, false); err != nil {
+		t.Fatalf("ExtractArchive failed: %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dst, "link")); err != nil || string(data) != "#!/bin/sh\n" {
+		t.Errorf("unexpected content through the link: %q, %v", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != filepath.Join("sub", "deep", "run.sh") {
+		t.Errorf("unexpected link: %q, %v", link, err)
+	}
+	for name, mode := range map[string]os.FileMode{"a.txt": 0640, "sub/deep/run.sh": 0750} {
+		stat, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if stat.Mode().Perm() != mode {
+			t.Errorf("%s: expected mode %v, got %v", name, mode, stat.Mode().Perm())
+		}
+	}
+	for _, name := range []string{"a.txt", "sub"} {
+		stat, err := os.Stat(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if !stat.ModTime().Equal(mtime) {
+			t.Errorf("%s: expected mtime %v, got %v", name, mtime, stat.ModTime())
+		}
+	}
+
+	if err := ExtractArchive(bytes.NewReader(buf.Bytes()), dst, false); err == nil {
+		t.Error("expected existing files to be kept")
+	}
+	if err := ExtractArchive(bytes.NewReader(buf.Bytes()), dst, true); err != nil {
+		t.Errorf("ExtractArchive with truncate failed: %v", err)
+	}
+}
+
+func TestArchive_PathTraversal(t *testing.T) {
+	cases := map[string][]*tar.Header{
+		"parent":   {{Name: "../evil", Typeflag: tar.TypeReg}},
+		"nested":   {{Name: "a/../../evil", Typeflag: tar.TypeReg}},
+		"absolute": {{Name: "/tmp/evil", Typeflag: tar.TypeReg}},
+		"link out": {{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "../.."}},
+		"link abs": {{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"}},
+		"through link": {
+			{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "."},
+			{Name: "link/evil", Typeflag: tar.TypeReg},
+		},
+		"hard link": {{Name: "hard", Typeflag: tar.TypeLink, Linkname: "/etc/passwd"}},
+		"device":    {{Name: "dev", Typeflag: tar.TypeChar}},
+	}
+	for name, headers := range cases {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range headers {
+			hdr.Mode = 0600
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatalf("%s: WriteHeader failed: %v", name, err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatalf("%s: Close failed: %v", name, err)
+		}
+		parent := t.TempDir()
+		dst := filepath.Join(parent, "dst")
+		err := ExtractArchive(&buf, dst, false)
+		if err == nil {
+			t.Errorf("%s: expected ExtractArchive to fail", name)
+		}
+		entries, _ := os.ReadDir(parent)
+		if len(entries) != 1 || entries[0].Name() != "dst" {
+			t.Errorf("%s: unexpected files out of the directory: %v", name, entries)
+		}
+		if _, err = os.Stat(filepath.Join(dst, "evil")); err == nil {
+			t.Errorf("%s: unexpected file through the link", name)
+		}
+	}
+}
+
+func TestWriteArchive_Exclude(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a.txt", "out.data"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(name), 0600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	out, err := os.Stat(filepath.Join(src, "out.data"))
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err = WriteArchive(&buf, src, out); err != nil {
+		t.Fatalf("WriteArchive failed: %v", err)
+	}
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	if len(names) != 1 || names[0] != "a.txt" {
+		t.Errorf("expected only a.txt in the archive, got %v", names)
+	}
+}
+
+func TestWriteArchive_NotDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	err := WriteArchive(&bytes.Buffer{}, path)
+	if err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("expected an error for a file, got %v", err)
+	}
+}
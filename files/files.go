@@ -30,7 +30,13 @@ func Stat(file string) (stat os.FileInfo, path string, err error) {
 	return
 }
 
+// StdStream is the file name standing for stdin as an input, or stdout as an output
+const StdStream = "-"
+
 func OpenInputFile(name string) (file *os.File, closeFn func(), err error) {
+	if strings.TrimSpace(name) == StdStream {
+		return os.Stdin, func() {}, nil
+	}
 	if file, err = openForRead(name); err != nil {
 		return
 	}
@@ -48,6 +54,9 @@ func OpenInputFile(name string) (file *os.File, closeFn func(), err error) {
 }
 
 func OpenOutputFile(name string, truncate bool, flags ...int) (file *os.File, closeFn func(), err error) {
+	if strings.TrimSpace(name) == StdStream {
+		return os.Stdout, func() {}, nil
+	}
 	if file, err = openForWrite(name, truncate, 0600, flags...); err != nil {
 		return
 	}
@@ -75,12 +84,16 @@ func openForRead(name string) (*os.File, error) {
 	if stat, path, err = Stat(name); err != nil {
 		return nil, err
 	}
-	if stat.Size() == 0 {
+	regular := stat.Mode().IsRegular()
+	if regular && stat.Size() == 0 {
 		return nil, fmt.Errorf("%s is empty", path)
 	}
 	if file, err = os.OpenFile(path, os.O_RDONLY, 0400); err != nil {
 		return nil, err
 	} else {
+		if !regular {
+			return file, nil
+		}
 		if err = AcquireSharedLock(file.Fd()); err != nil {
 			_ = file.Close()
 			return nil, err
@@ -112,6 +125,9 @@ func openForWrite(name string, truncate bool, mode os.FileMode, flags ...int) (*
 	if stat, path, err = Stat(name); err != nil {
 		return nil, err
 	}
+	if !stat.Mode().IsRegular() {
+		return file, nil
+	}
 	if stat.Size() > 0 {
 		return nil, fmt.Errorf("%s is not empty", path)
 	}
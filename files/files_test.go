@@ -1,8 +1,10 @@
 package files
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 )
 
@@ -232,4 +234,57 @@ func FuzzStat(f *testing.F) {
 		// Stat should never panic regardless of input
 		_, _, _ = Stat(path)
 	})
-}
\ No newline at end of file
+}
+
+func TestOpenInputFile_Stdin(t *testing.T) {
+	file, closeFn, err := OpenInputFile(StdStream)
+	if err != nil {
+		t.Fatalf("OpenInputFile(-) failed: %v", err)
+	}
+	defer closeFn()
+	if file != os.Stdin {
+		t.Error("expected stdin for '-'")
+	}
+}
+
+func TestOpenOutputFile_Stdout(t *testing.T) {
+	file, closeFn, err := OpenOutputFile(StdStream, false)
+	if err != nil {
+		t.Fatalf("OpenOutputFile(-) failed: %v", err)
+	}
+	defer closeFn()
+	if file != os.Stdout {
+		t.Error("expected stdout for '-'")
+	}
+}
+
+func TestOpenInputFile_Pipe(t *testing.T) {
+	if _, err := os.Stat("/dev/fd"); err != nil {
+		t.Skipf("/dev/fd is unavailable: %v", err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	// An empty pipe is not rejected like an empty regular file
+	file, closeFn, err := OpenInputFile("/dev/fd/" + strconv.Itoa(int(r.Fd())))
+	if err != nil {
+		t.Fatalf("OpenInputFile of an empty pipe failed: %v", err)
+	}
+	defer closeFn()
+	if file == nil {
+		t.Fatal("OpenInputFile returned a nil file")
+	}
+	if stat, err := file.Stat(); err != nil || stat.Mode()&os.ModeNamedPipe == 0 {
+		t.Fatalf("expected a pipe, got %v, %v", stat, err)
+	}
+	if _, err = w.WriteString("piped"); err != nil {
+		t.Fatalf("failed to write pipe: %v", err)
+	}
+	w.Close()
+	if data, err := io.ReadAll(file); err != nil || string(data) != "piped" {
+		t.Errorf("expected to read %q from the pipe, got %q, %v", "piped", data, err)
+	}
+}
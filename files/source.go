@@ -0,0 +1,135 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Prefixes of the key sources which are not paths
+const (
+	SourceFd   = "fd:"
+	SourceEnv  = "env:"
+	SourceFile = "file:"
+)
+
+// maxSourceSize is the largest key material read from a source, which is far above any key or share file
+const maxSourceSize = 1 << 20
+
+// streamSources holds the key material read from the sources which cannot be read twice,
+// the descriptors and stdin, for the callers which try a source as more than one kind of key,
+// until ForgetSources wipes it
+var streamSources sync.Map
+
+// ReadSource reads all the key material of a source, which is one of
+//
+//	fd:<N>       an inherited file descriptor other than 0-2, which is closed afterwards
+//	env:<NAME>   an environment variable
+//	file:<path>  a path, also as a file URI like file:///path
+//	-            stdin
+//
+// or else a path. Sources other than paths keep key material off any filesystem.
+// Descriptors and stdin are read once, and their key material is returned again for the same name
+// until ForgetSources.
+func ReadSource(name string) ([]byte, error) {
+	name = strings.TrimSpace(name)
+	if name != StdStream && !strings.HasPrefix(name, SourceFd) {
+		return readSourceOnce(name)
+	}
+	if b, ok := streamSources.Load(name); ok {
+		return bytes.Clone(b.([]byte)), nil
+	}
+	b, err := readSourceOnce(name)
+	if err != nil {
+		return nil, err
+	}
+	streamSources.Store(name, bytes.Clone(b))
+	return b, nil
+}
+
+// ForgetSources wipes the key material kept from the descriptors and stdin, once the keys are read from them,
+// so that it does not stay in memory for the life of the process
+func ForgetSources() {
+	streamSources.Range(func(name, b any) bool {
+		clear(b.([]byte))
+		streamSources.Delete(name)
+		return true
+	})
+}
+
+func readSourceOnce(name string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(name, SourceFd):
+		fd, err := strconv.Atoi(strings.TrimPrefix(name, SourceFd))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("%s: invalid file descriptor", name)
+		}
+		if fd <= 2 {
+			// Closing them afterwards would take stdin, stdout or stderr away from the command
+			return nil, fmt.Errorf("%s: file descriptor of stdin, stdout or stderr, use '-' for stdin", name)
+		}
+		file := os.NewFile(uintptr(fd), name)
+		if file == nil {
+			return nil, fmt.Errorf("%s: invalid file descriptor", name)
+		}
+		defer func() { _ = file.Close() }()
+		return readSource(name, file)
+	case strings.HasPrefix(name, SourceEnv):
+		key := strings.TrimPrefix(name, SourceEnv)
+		value, ok := os.LookupEnv(key)
+		if key == "" || !ok {
+			return nil, fmt.Errorf("%s: environment variable is not set", name)
+		}
+		return readSource(name, strings.NewReader(value))
+	case strings.HasPrefix(name, SourceFile):
+		path := strings.TrimPrefix(name, SourceFile)
+		if rest, ok := strings.CutPrefix(path, "//"); ok {
+			// A file URI names a local path after an empty host or localhost
+			if rest = strings.TrimPrefix(rest, "localhost"); !strings.HasPrefix(rest, "/") {
+				return nil, fmt.Errorf("%s: file URI of another host", name)
+			}
+			path = rest
+		}
+		if path == "" {
+			return nil, fmt.Errorf("%s: empty path", name)
+		}
+		return readPath(path)
+	case name == StdStream:
+		return readSource("stdin", os.Stdin)
+	default:
+		return readPath(name)
+	}
+}
+
+// IsSource reports whether name is a key source other than a path
+func IsSource(name string) bool {
+	name = strings.TrimSpace(name)
+	return name == StdStream || strings.HasPrefix(name, SourceFd) ||
+		strings.HasPrefix(name, SourceEnv) || strings.HasPrefix(name, SourceFile)
+}
+
+func readPath(path string) ([]byte, error) {
+	file, closeFn, err := OpenInputFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+	return readSource(file.Name(), file)
+}
+
+func readSource(name string, r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxSourceSize+1))
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("%s: %w", name, err)
+	case len(b) == 0:
+		return nil, fmt.Errorf("%s is empty", name)
+	case len(b) > maxSourceSize:
+		return nil, fmt.Errorf("%s: key material larger than %d bytes", name, maxSourceSize)
+	}
+	return b, nil
+}
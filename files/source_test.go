@@ -0,0 +1,54 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key.json")
+	if err := os.WriteFile(path, []byte("from file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FORTIFY_TEST_KEY", "from env")
+	cases := map[string]string{
+		path:                      "from file",
+		"file:" + path:            "from file",
+		"file://" + path:          "from file",
+		"file://localhost" + path: "from file",
+		"env:FORTIFY_TEST_KEY":    "from env",
+	}
+	for name, expect := range cases {
+		b, err := ReadSource(name)
+		if err != nil {
+			t.Errorf("%s: ReadSource failed: %v", name, err)
+		} else if string(b) != expect {
+			t.Errorf("%s: expected %q, got %q", name, expect, b)
+		}
+	}
+}
+
+func TestReadSource_Invalid(t *testing.T) {
+	t.Setenv("FORTIFY_TEST_EMPTY", "")
+	t.Setenv("FORTIFY_TEST_LARGE", strings.Repeat("k", maxSourceSize+1))
+	for _, name := range []string{
+		"fd:x",
+		"fd:-1",
+		"fd:0",
+		"fd:2",
+		"env:",
+		"env:FORTIFY_TEST_UNSET",
+		"env:FORTIFY_TEST_EMPTY",
+		"env:FORTIFY_TEST_LARGE",
+		"file:",
+		"file://example.com/key.json",
+		filepath.Join(t.TempDir(), "nonexistent.json"),
+	} {
+		if _, err := ReadSource(name); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
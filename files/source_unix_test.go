@@ -0,0 +1,52 @@
+//go:build unix
+
+package files
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestReadSource_Fd(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	go func() {
+		_, _ = w.WriteString("from fd")
+		_ = w.Close()
+	}()
+	// ReadSource closes the descriptor it reads, so it gets a duplicate of the one r keeps
+	fd, err := syscall.Dup(int(r.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadSource("fd:" + strconv.Itoa(fd))
+	if err != nil {
+		t.Fatalf("ReadSource failed: %v", err)
+	}
+	if string(b) != "from fd" {
+		t.Errorf("expected %q, got %q", "from fd", b)
+	}
+	var stat syscall.Stat_t
+	if err = syscall.Fstat(fd, &stat); err == nil {
+		t.Errorf("file descriptor %d is still open", fd)
+	}
+	// The descriptor is read once, and its key material is returned again
+	if b, err = ReadSource("fd:" + strconv.Itoa(fd)); err != nil || string(b) != "from fd" {
+		t.Errorf("expected %q again, got %q, %v", "from fd", b, err)
+	}
+	// Until it is forgotten and wiped
+	kept, _ := streamSources.Load("fd:" + strconv.Itoa(fd))
+	ForgetSources()
+	if _, ok := streamSources.Load("fd:" + strconv.Itoa(fd)); ok {
+		t.Error("expected the key material to be forgotten")
+	}
+	if !bytes.Equal(kept.([]byte), make([]byte, len("from fd"))) {
+		t.Errorf("expected the key material to be wiped, got %q", kept)
+	}
+}
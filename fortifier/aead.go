@@ -0,0 +1,161 @@
+package fortifier
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// aeadChunkSize is the plaintext size of every chunk but the last one
+const aeadChunkSize = 64 * 1024
+
+// aeadSaltSize is the size of the random salt stored in place of the IV
+const aeadSaltSize = 32
+
+var ErrTruncatedChunks = errors.New("truncated chunks of data")
+
+type aeadCipher struct {
+	cipher.AEAD
+	nonce []byte
+}
+
+// newAeadChunkCipher derives a per-file key from the data key and the salt,
+// so that chunk counters can start from zero in every fortified file.
+func newAeadChunkCipher(mode CipherMode, key, salt []byte) (aead aeadCipher, err error) {
+	var sub []byte
+	if sub, err = hkdf.Key(sha256.New, key, salt, mode.Name.String(), 32); err != nil {
+		return
+	}
+	if aead.AEAD, err = mode.AeadMaker(sub); err != nil {
+		return
+	}
+	aead.nonce = make([]byte, aead.NonceSize())
+	return
+}
+
+// aeadChunkNonce puts the chunk counter and the final flag into the tail of nonce,
+// so that reordered, dropped or truncated chunks fail authentication.
+func aeadChunkNonce(nonce []byte, counter uint64, final bool) []byte {
+	size := len(nonce)
+	binary.BigEndian.PutUint64(nonce[size-9:size-1], counter)
+	if final {
+		nonce[size-1] = 1
+	} else {
+		nonce[size-1] = 0
+	}
+	return nonce
+}
+
+type aeadChunkWriter struct {
+	aeadCipher
+	w       io.Writer
+	plain   []byte
+	sealed  []byte
+	counter uint64
+}
+
+func newAeadChunkWriter(aead aeadCipher, w io.Writer) *aeadChunkWriter {
+	return &aeadChunkWriter{
+		aeadCipher: aead,
+		w:          w,
+		plain:      make([]byte, 0, aeadChunkSize),
+		sealed:     make([]byte, 0, aeadChunkSize+aead.Overhead()),
+	}
+}
+
+func (w *aeadChunkWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if len(w.plain) == aeadChunkSize {
+			if err = w.seal(false); err != nil {
+				return
+			}
+		}
+		size := len(w.plain)
+		copied := copy(w.plain[size:aeadChunkSize], p)
+		w.plain = w.plain[:size+copied]
+		p = p[copied:]
+		n += copied
+	}
+	return
+}
+
+// Close seals the buffered plaintext as the final chunk, which may be empty.
+func (w *aeadChunkWriter) Close() error {
+	return w.seal(true)
+}
+
+func (w *aeadChunkWriter) seal(final bool) (err error) {
+	nonce := aeadChunkNonce(w.nonce, w.counter, final)
+	w.sealed = w.Seal(w.sealed[:0], nonce, w.plain, nil)
+	if _, err = w.w.Write(w.sealed); err != nil {
+		return
+	}
+	w.counter++
+	w.plain = w.plain[:0]
+	return
+}
+
+type aeadChunkReader struct {
+	aeadCipher
+	r       *bufio.Reader
+	sealed  []byte
+	opened  []byte
+	plain   []byte
+	counter uint64
+	done    bool
+}
+
+func newAeadChunkReader(aead aeadCipher, r *bufio.Reader) *aeadChunkReader {
+	return &aeadChunkReader{
+		aeadCipher: aead,
+		r:          r,
+		sealed:     make([]byte, aeadChunkSize+aead.Overhead()),
+		opened:     make([]byte, 0, aeadChunkSize),
+	}
+}
+
+func (r *aeadChunkReader) Read(p []byte) (n int, err error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err = r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n = copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return
+}
+
+// open authenticates the next chunk before any of its plaintext is released.
+func (r *aeadChunkReader) open() (err error) {
+	var n int
+	final := false
+	if n, err = io.ReadFull(r.r, r.sealed); err != nil {
+		if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return
+		}
+		final = true
+	} else if _, err = r.r.Peek(1); err != nil {
+		if !errors.Is(err, io.EOF) {
+			return
+		}
+		final = true
+	}
+	if n < r.Overhead() {
+		return ErrTruncatedChunks
+	}
+	nonce := aeadChunkNonce(r.nonce, r.counter, final)
+	if r.plain, err = r.Open(r.opened[:0], nonce, r.sealed[:n], nil); err != nil {
+		return fmt.Errorf("%w: invalid chunk %d of data: %w", ErrInvalidChecksum, r.counter, err)
+	}
+	r.counter++
+	r.done = final
+	return
+}
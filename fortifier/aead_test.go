@@ -0,0 +1,177 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encryptToBytes encrypts plaintext with a fresh SSS key and returns the fortified bytes
+func encryptToBytes(t *testing.T, mode CipherModeName, plaintext []byte) (*Fortifier, []byte) {
+	t.Helper()
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "plain.bin")
+	outPath := filepath.Join(dir, "fortified.bin")
+	if err := os.WriteFile(inPath, plaintext, 0644); err != nil {
+		t.Fatalf("failed to write input: %v", err)
+	}
+	in, err := os.Open(inPath)
+	if err != nil {
+		t.Fatalf("failed to open input: %v", err)
+	}
+	defer in.Close()
+	out, err := os.Create(outPath)
+	if err != nil {
+		t.Fatalf("failed to create output: %v", err)
+	}
+	defer out.Close()
+	f := NewFortifierWithSss(false, true, nil)
+	if err = f.SetupKey(); err != nil {
+		t.Fatalf("SetupKey failed: %v", err)
+	}
+	if err = NewEncrypter(mode, f).EncryptFile(in, out); err != nil {
+		t.Fatalf("EncryptFile failed: %v", err)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	return f, data
+}
+
+// decryptBytes decrypts fortified bytes with the key of f and returns the released plaintext
+func decryptBytes(f *Fortifier, data []byte) ([]byte, error) {
+	r := bytes.NewReader(data)
+	layout := &FileLayout{}
+	if err := layout.ReadHeadIn(r); err != nil {
+		return nil, err
+	}
+	f2 := &Fortifier{meta: layout.Metadata(), key: f.key, block: f.block}
+	var buf bytes.Buffer
+	err := NewDecrypter(layout.Metadata().Mode, f2).Decrypt(r, &buf, layout)
+	return buf.Bytes(), err
+}
+
+func TestAeadStream_MultipleChunks(t *testing.T) {
+	for _, size := range []int{0, 1, aeadChunkSize - 1, aeadChunkSize, aeadChunkSize + 1, 3 * aeadChunkSize} {
+		plaintext := bytes.Repeat([]byte{0xA5}, size)
+		f, data := encryptToBytes(t, CipherModeAes256GCM, plaintext)
+		decrypted, err := decryptBytes(f, data)
+		if err != nil {
+			t.Fatalf("size %d: Decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("size %d: decrypted content mismatch", size)
+		}
+	}
+}
+
+func TestAeadStream_XChaCha20(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("xchacha20-poly1305"), aeadChunkSize/6)
+	f, data := encryptToBytes(t, CipherModeXChaCha20Poly1305, plaintext)
+	decrypted, err := decryptBytes(f, data)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !bytes.Equal(plaintext, decrypted) {
+		t.Error("decrypted content mismatch")
+	}
+	data[len(data)-1] ^= 0x80
+	if _, err = decryptBytes(f, data); err == nil {
+		t.Error("expected error for tampered tag")
+	}
+}
+
+func TestAeadStream_TamperedChunk(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("0123456789abcdef"), aeadChunkSize/8)
+	f, data := encryptToBytes(t, CipherModeAes256GCM, plaintext)
+	// Flip a byte inside the second chunk
+	data[len(data)-aeadChunkSize/2] ^= 0x01
+	decrypted, err := decryptBytes(f, data)
+	if err == nil {
+		t.Fatal("expected error for tampered chunk")
+	}
+	if len(decrypted) > aeadChunkSize {
+		t.Errorf("plaintext of the tampered chunk was released: %d bytes", len(decrypted))
+	}
+}
+
+func TestAeadStream_TruncatedChunks(t *testing.T) {
+	plaintext := bytes.Repeat([]byte{0x5A}, 2*aeadChunkSize+100)
+	f, data := encryptToBytes(t, CipherModeAes256GCM, plaintext)
+	// Drop the final chunk so that the stream ends on a chunk boundary
+	truncated := data[:len(data)-(100+16)]
+	if _, err := decryptBytes(f, truncated); err == nil {
+		t.Fatal("expected error for truncated chunks")
+	}
+	// Drop everything after the salt
+	head := len(data) - (2*(aeadChunkSize+16) + 100 + 16)
+	if _, err := decryptBytes(f, data[:head]); !errors.Is(err, ErrTruncatedChunks) {
+		t.Fatalf("expected ErrTruncatedChunks, got %v", err)
+	}
+}
+
+func TestAeadStream_ReorderedChunks(t *testing.T) {
+	plaintext := make([]byte, 3*aeadChunkSize)
+	for i := range plaintext {
+		plaintext[i] = byte(i / aeadChunkSize)
+	}
+	f, data := encryptToBytes(t, CipherModeAes256GCM, plaintext)
+	sealed := aeadChunkSize + 16
+	start := len(data) - 3*sealed - 16
+	reordered := make([]byte, 0, len(data))
+	reordered = append(reordered, data[:start]...)
+	reordered = append(reordered, data[start+sealed:start+2*sealed]...)
+	reordered = append(reordered, data[start:start+sealed]...)
+	reordered = append(reordered, data[start+2*sealed:]...)
+	decrypted, err := decryptBytes(f, reordered)
+	if err == nil {
+		t.Fatal("expected error for reordered chunks")
+	}
+	if len(decrypted) != 0 {
+		t.Errorf("expected no plaintext released, got %d bytes", len(decrypted))
+	}
+}
+
+func TestTrailerLayout_RoundTrip(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("piped"), 100000)
+	for _, mode := range []CipherModeName{CipherModeAes256CTR, CipherModeAes256GCM} {
+		f := NewFortifierWithSss(false, true, nil)
+		if err := f.SetupKey(); err != nil {
+			t.Fatalf("SetupKey failed: %v", err)
+		}
+		f.meta.Mode = mode
+		// A bytes.Buffer cannot seek, so the checksums go into the trailer
+		var out bytes.Buffer
+		layout := &FileLayout{metadata: f.meta}
+		enc := &Aes256StreamEncrypter{f}
+		m := CipherMode{Name: mode, StreamMaker: cipher.NewCTR}
+		if mode == CipherModeAes256GCM {
+			m = CipherMode{Name: mode, AeadMaker: newAes256GCM}
+		}
+		if err := enc.Encrypt(bytes.NewReader(plaintext), &out, layout, m); err != nil {
+			t.Fatalf("%s: Encrypt failed: %v", mode, err)
+		}
+		if !layout.HasTrailer() {
+			t.Fatalf("%s: expected a trailer layout", mode)
+		}
+		data := out.Bytes()
+		decrypted, err := decryptBytes(f, data)
+		if err != nil {
+			t.Fatalf("%s: Decrypt failed: %v", mode, err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("%s: decrypted content mismatch", mode)
+		}
+		data[len(data)-1] ^= 0x01
+		if _, err = decryptBytes(f, data); err == nil {
+			t.Errorf("%s: expected error for tampered trailer", mode)
+		}
+		if _, err = decryptBytes(f, data[:len(data)-layoutTrailerSize/2]); err == nil {
+			t.Errorf("%s: expected error for truncated trailer", mode)
+		}
+	}
+}
@@ -3,11 +3,11 @@ package fortifier
 import (
 	"bufio"
 	"bytes"
-	"crypto/cipher"
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"time"
@@ -16,6 +16,13 @@ import (
 const defaultReaderBufferSize = 128 * 1024
 const defaultWriterBufferSize = 256 * 1024
 
+var (
+	ErrKeyDigest           = errors.New("mismatched key digest")
+	ErrInvalidHeadChecksum = errors.New("invalid checksum of meta")
+	ErrDataLength          = errors.New("mismatched data length")
+	ErrInvalidChecksum     = errors.New("invalid checksum of file")
+)
+
 type Aes256StreamEncrypter struct {
 	*Fortifier
 }
@@ -33,7 +40,7 @@ func (f *Aes256StreamEncrypter) EncryptFile(in, out *os.File, mode CipherMode) (
 	}
 	started := time.Now()
 	layout := &FileLayout{metadata: f.meta}
-	if err = f.Encrypt(in, out, layout, mode); err != nil {
+	if err = f.encryptStream(in, out, layout, mode); err != nil {
 		return
 	}
 	if f.verbose {
@@ -42,9 +49,27 @@ func (f *Aes256StreamEncrypter) EncryptFile(in, out *os.File, mode CipherMode) (
 	return
 }
 
+// encryptStream sets up the key and encrypts in to out, with a trailer if out is not a regular file.
+func (f *Aes256StreamEncrypter) encryptStream(in io.Reader, out io.Writer, layout *FileLayout, mode CipherMode) (err error) {
+	if err = f.SetupKey(); err != nil {
+		return
+	}
+	if file, ok := out.(*os.File); ok {
+		if layout.trailer, err = notRegularFile(file); err != nil {
+			return
+		}
+	}
+	return f.Encrypt(in, out, layout, mode)
+}
+
+// Encrypt writes the checksums after the data if layout requires a trailer or out cannot seek.
 func (f *Aes256StreamEncrypter) Encrypt(
-	in io.Reader, out io.WriteSeeker, layout *FileLayout, mode CipherMode) (err error) {
-	iv := make([]byte, f.block.BlockSize())
+	in io.Reader, out io.Writer, layout *FileLayout, mode CipherMode) (err error) {
+	seeker, ok := out.(io.WriteSeeker)
+	if !ok {
+		layout.trailer = true
+	}
+	iv := make([]byte, mode.ivSize(f.block))
 	if _, err = rand.Read(iv); err != nil {
 		return
 	}
@@ -57,22 +82,41 @@ func (f *Aes256StreamEncrypter) Encrypt(
 	}
 	check := f.key.NewSha256()
 	check.Write(iv)
-	stream := mode.StreamMaker(f.block, iv)
-	writer := io.MultiWriter(check, cipher.StreamWriter{S: stream, W: ow})
+	var cw io.WriteCloser
+	if cw, err = mode.newWriter(f.block, f.key.raw, iv, ow); err != nil {
+		return
+	}
+	payload := &countWriter{w: io.MultiWriter(check, cw)}
+	var zw io.WriteCloser
+	if zw, err = newCompressWriter(f.meta.Compression, payload); err != nil {
+		return
+	}
 	ir := bufio.NewReaderSize(in, defaultReaderBufferSize)
-	var cnt int64
-	if cnt, err = io.Copy(writer, ir); err != nil {
+	if _, err = io.Copy(zw, ir); err != nil {
 		return
 	}
-	if err = ow.Flush(); err != nil {
+	if err = zw.Close(); err != nil {
 		return
 	}
-	if file, ok := out.(*os.File); ok {
-		if err = file.Sync(); err != nil {
+	if err = cw.Close(); err != nil {
+		return
+	}
+	cnt := payload.n
+	if layout.trailer {
+		if err = layout.WriteTailOut(ow, f.key, check, cnt); err != nil {
 			return
 		}
 	}
-	if err = layout.WriteHeadPlaceHolders(out, f.key, check, cnt); err != nil {
+	if err = ow.Flush(); err != nil {
+		return
+	}
+	if err = syncFile(out); err != nil {
+		return
+	}
+	if layout.trailer {
+		return
+	}
+	if err = layout.WriteHeadPlaceHolders(seeker, f.key, check, cnt); err != nil {
 		return
 	}
 	return
@@ -104,62 +148,132 @@ func (f *Aes256StreamDecrypter) DecryptFile(in, out *os.File, layout *FileLayout
 	return
 }
 
-func (f *Aes256StreamDecrypter) Decrypt(in io.Reader, w io.Writer, layout *FileLayout, mode CipherMode) (err error) {
+func (f *Aes256StreamDecrypter) Decrypt(in io.Reader, w io.Writer, layout *FileLayout, mode CipherMode) error {
+	return f.decrypt(in, w, layout, mode, nil)
+}
+
+// decrypt also feeds the IV and the decrypted data to recheck if it is not nil,
+// to compute the checksum of the same data under another head.
+func (f *Aes256StreamDecrypter) decrypt(
+	in io.Reader, w io.Writer, layout *FileLayout, mode CipherMode, recheck hash.Hash) (err error) {
 	if err = f.SetupKey(); err != nil {
 		return
 	}
-	expect := layout.headChecksum
-	actual := layout.makeChecksumHead(f.key)
-	if !bytes.Equal(expect, actual) {
-		return errors.New("invalid checksum of meta")
+	if !layout.trailer {
+		if err = f.verifyHead(layout); err != nil {
+			return
+		}
 	}
 	meta := layout.Metadata()
 	if meta.Mode != mode.Name {
 		return fmt.Errorf("requires cipher mode: %s", meta.Mode)
 	}
 	if f.meta.Sss != nil && meta.Sss.Digest != f.meta.Sss.Digest {
-		return errors.New("mismatched key digest")
+		return ErrKeyDigest
 	}
 	f.meta.Mode = meta.Mode
 	f.meta.Timestamp = meta.Timestamp
-	iv := make([]byte, f.block.BlockSize())
+	var tr *trailerReader
+	if layout.trailer {
+		tr = newTrailerReader(in, layoutTrailerSize)
+		in = tr
+	}
+	iv := make([]byte, mode.ivSize(f.block))
 	ir := bufio.NewReaderSize(in, defaultReaderBufferSize)
 	if err = binary.Read(ir, layoutByteOrder, iv); err != nil {
 		return
 	}
 	check := f.key.NewSha256()
-	stream := mode.StreamMaker(f.block, iv)
-	var writer io.Writer
-	var ow *bufio.Writer
-	if w != nil {
-		ow = bufio.NewWriterSize(w, defaultWriterBufferSize)
-		writer = io.MultiWriter(ow, check)
-	} else {
-		writer = check
+	var reader io.Reader
+	if reader, err = mode.newReader(f.block, f.key.raw, iv, ir); err != nil {
+		return
 	}
-	reader := cipher.StreamReader{S: stream, R: ir}
+	var payload io.Writer = check
 	check.Write(iv)
+	if recheck != nil {
+		recheck.Write(iv)
+		payload = io.MultiWriter(check, recheck)
+	}
+	var ow *bufio.Writer
 	var cnt int64
-	if cnt, err = io.Copy(writer, reader); err != nil {
+	var zerr error
+	if w == nil {
+		cnt, err = io.Copy(payload, reader)
+	} else if ow = bufio.NewWriterSize(w, defaultWriterBufferSize); meta.Compression == CompressionNone {
+		cnt, err = io.Copy(io.MultiWriter(ow, payload), reader)
+	} else {
+		counter := &countWriter{w: payload}
+		zerr, err = decompressTo(meta.Compression, ow, io.TeeReader(reader, counter))
+		cnt = counter.n
+	}
+	if err != nil {
 		return
 	}
+	if tr != nil {
+		if err = layout.ReadTailIn(tr.Trailer()); err != nil {
+			return
+		}
+		if err = f.verifyHead(layout); err != nil {
+			return
+		}
+	}
 	if uint64(cnt) != layout.dataLength {
-		return fmt.Errorf("expect data length is %d, not %d\n", layout.dataLength, cnt)
+		return fmt.Errorf("%w: expect data length is %d, not %d", ErrDataLength, layout.dataLength, cnt)
 	}
 	check.Write(layout.headChecksum)
 	sum := check.Sum(nil)
 	if !bytes.Equal(layout.checksum, sum) {
-		return errors.New("invalid checksum of file")
+		return ErrInvalidChecksum
+	}
+	if zerr != nil {
+		return zerr
 	}
 	if ow != nil {
 		if err = ow.Flush(); err != nil {
 			return
 		}
 	}
-	if file, ok := w.(*os.File); ok {
-		if err = file.Sync(); err != nil {
-			return
-		}
+	return syncFile(w)
+}
+
+// decompressTo decompresses the data read from r to w, then reads r through to the end. An error of the
+// decompression is returned apart as zerr, as tampered data is better reported by the checksum of the file.
+func decompressTo(c CompressionName, w io.Writer, r io.Reader) (zerr, err error) {
+	var zr io.ReadCloser
+	if zr, zerr = newDecompressReader(c, r); zerr == nil {
+		_, zerr = io.Copy(w, zr)
+		_ = zr.Close()
 	}
+	_, err = io.Copy(io.Discard, r)
 	return
 }
+
+func (f *Aes256StreamDecrypter) verifyHead(layout *FileLayout) error {
+	expect := layout.headChecksum
+	actual := layout.makeChecksumHead(f.key)
+	if !bytes.Equal(expect, actual) {
+		return ErrInvalidHeadChecksum
+	}
+	return nil
+}
+
+// notRegularFile reports whether file is a pipe, a terminal or another kind of special file
+func notRegularFile(file *os.File) (bool, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return !stat.Mode().IsRegular(), nil
+}
+
+// syncFile commits w to the storage if it is a regular file
+func syncFile(w any) error {
+	file, ok := w.(*os.File)
+	if !ok {
+		return nil
+	}
+	if special, err := notRegularFile(file); err != nil || special {
+		return err
+	}
+	return file.Sync()
+}
@@ -20,6 +20,12 @@ func (f *Aes256EncrypterCFB) EncryptFile(in, out *os.File) error {
 		CipherMode{Name: CipherModeAes256CFB, StreamMaker: cipher.NewCFBEncrypter})
 }
 
+func (f *Aes256EncrypterCFB) Encrypt(r io.Reader, w io.Writer) error {
+	f.meta.Mode = CipherModeAes256CFB
+	return f.Aes256StreamEncrypter.encryptStream(r, w, &FileLayout{metadata: f.meta},
+		CipherMode{Name: CipherModeAes256CFB, StreamMaker: cipher.NewCFBEncrypter})
+}
+
 type Aes256DecrypterCFB struct {
 	Aes256StreamDecrypter
 }
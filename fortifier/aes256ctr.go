@@ -20,6 +20,12 @@ func (f *Aes256EncrypterCTR) EncryptFile(in, out *os.File) error {
 		CipherMode{Name: CipherModeAes256CTR, StreamMaker: cipher.NewCTR})
 }
 
+func (f *Aes256EncrypterCTR) Encrypt(r io.Reader, w io.Writer) error {
+	f.meta.Mode = CipherModeAes256CTR
+	return f.Aes256StreamEncrypter.encryptStream(r, w, &FileLayout{metadata: f.meta},
+		CipherMode{Name: CipherModeAes256CTR, StreamMaker: cipher.NewCTR})
+}
+
 type Aes256DecrypterCTR struct {
 	Aes256StreamDecrypter
 }
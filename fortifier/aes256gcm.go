@@ -0,0 +1,54 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"os"
+)
+
+func newAes256GCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type Aes256EncrypterGCM struct {
+	Aes256StreamEncrypter
+}
+
+func NewAes256EncrypterGCM(f *Fortifier) *Aes256EncrypterGCM {
+	return &Aes256EncrypterGCM{Aes256StreamEncrypter{f}}
+}
+
+func (f *Aes256EncrypterGCM) EncryptFile(in, out *os.File) error {
+	f.meta.Mode = CipherModeAes256GCM
+	return f.Aes256StreamEncrypter.EncryptFile(in, out,
+		CipherMode{Name: CipherModeAes256GCM, AeadMaker: newAes256GCM})
+}
+
+func (f *Aes256EncrypterGCM) Encrypt(r io.Reader, w io.Writer) error {
+	f.meta.Mode = CipherModeAes256GCM
+	return f.Aes256StreamEncrypter.encryptStream(r, w, &FileLayout{metadata: f.meta},
+		CipherMode{Name: CipherModeAes256GCM, AeadMaker: newAes256GCM})
+}
+
+type Aes256DecrypterGCM struct {
+	Aes256StreamDecrypter
+}
+
+func NewAes256DecrypterGCM(f *Fortifier) *Aes256DecrypterGCM {
+	return &Aes256DecrypterGCM{Aes256StreamDecrypter{f}}
+}
+
+func (f *Aes256DecrypterGCM) Decrypt(r io.Reader, w io.Writer, layout *FileLayout) error {
+	return f.Aes256StreamDecrypter.Decrypt(r, w, layout,
+		CipherMode{Name: CipherModeAes256GCM, AeadMaker: newAes256GCM})
+}
+
+func (f *Aes256DecrypterGCM) DecryptFile(in, out *os.File, layout *FileLayout) error {
+	return f.Aes256StreamDecrypter.DecryptFile(in, out, layout,
+		CipherMode{Name: CipherModeAes256GCM, AeadMaker: newAes256GCM})
+}
@@ -20,6 +20,12 @@ func (f *Aes256EncrypterOFB) EncryptFile(in, out *os.File) error {
 		CipherMode{Name: CipherModeAes256OFB, StreamMaker: cipher.NewOFB})
 }
 
+func (f *Aes256EncrypterOFB) Encrypt(r io.Reader, w io.Writer) error {
+	f.meta.Mode = CipherModeAes256OFB
+	return f.Aes256StreamEncrypter.encryptStream(r, w, &FileLayout{metadata: f.meta},
+		CipherMode{Name: CipherModeAes256OFB, StreamMaker: cipher.NewOFB})
+}
+
 type Aes256DecrypterOFB struct {
 	Aes256StreamDecrypter
 }
@@ -0,0 +1,116 @@
+package fortifier
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
+)
+
+const ageFortifier = "age_fortifier"
+
+// ExportAge decrypts the fortified data in r and encrypts it again for the age recipients into w,
+// so that the plaintext never leaves the process. The age file is left unfinished if the
+// checksum of the fortified data fails, which age refuses to decrypt.
+func ExportAge(dec Decrypter, r io.Reader, w io.Writer, layout *FileLayout, recipients ...age.Recipient) error {
+	aw, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return fmt.Errorf("%s: %v", ageFortifier, err)
+	}
+	if err = dec.Decrypt(r, aw, layout); err != nil {
+		return err
+	}
+	if err = aw.Close(); err != nil {
+		return err
+	}
+	return syncFile(w)
+}
+
+// ImportAge decrypts the age file in r with one of the identities and fortifies it into w.
+func ImportAge(enc Encrypter, r io.Reader, w io.Writer, identities ...age.Identity) error {
+	ar, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return fmt.Errorf("%s: %v", ageFortifier, err)
+	}
+	return enc.Encrypt(ar, w)
+}
+
+// ParseAgeRecipients parses X25519 recipients ("age1...") and ssh-rsa public keys, one per line,
+// or a single RSA public key in any of the formats accepted by the rsa key kind.
+func ParseAgeRecipients(kb []byte) (recipients []age.Recipient, err error) {
+	if len(decodePemBlocks(kb)) > 0 || bytes.Contains(kb, []byte("---- BEGIN SSH2 PUBLIC KEY ----")) {
+		var r age.Recipient
+		if r, err = newAgeRsaRecipient(kb); err != nil {
+			return nil, err
+		}
+		return []age.Recipient{r}, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(kb))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r age.Recipient
+		if strings.HasPrefix(line, "age1") {
+			r, err = age.ParseX25519Recipient(line)
+		} else {
+			r, err = newAgeRsaRecipient([]byte(line))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: recipient at line %d: %v", ageFortifier, n, err)
+		}
+		recipients = append(recipients, r)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%s: no recipients found", ageFortifier)
+	}
+	return
+}
+
+func newAgeRsaRecipient(kb []byte) (age.Recipient, error) {
+	pub, err := parseRsaPublicKey(kb)
+	if err != nil {
+		return nil, err
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return agessh.NewRSARecipient(sshPub)
+}
+
+// ParseAgeIdentities parses an age identity file of X25519 secret keys ("AGE-SECRET-KEY-1..."),
+// or an RSA private key in any of the formats accepted by the rsa key kind.
+func ParseAgeIdentities(kb []byte) ([]age.Identity, error) {
+	if bytes.Contains(kb, []byte("AGE-SECRET-KEY-1")) {
+		identities, err := age.ParseIdentities(bytes.NewReader(kb))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ageFortifier, err)
+		}
+		return identities, nil
+	}
+	k, err := parsePrivateKey(ageFortifier, kb)
+	if err != nil {
+		return nil, err
+	}
+	pri, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: requiring *rsa.PrivateKey, not %v", ageFortifier, reflect.TypeOf(k))
+	}
+	identity, err := agessh.NewRSAIdentity(pri)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Identity{identity}, nil
+}
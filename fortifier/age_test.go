@@ -0,0 +1,112 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestExportImportAge_RoundTrip(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("fortify to age and back "), 10000)
+	f, data := encryptToBytes(t, CipherModeAes256GCM, plaintext)
+
+	x25519, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity failed: %v", err)
+	}
+	pri, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(&pri.PublicKey)
+	if err != nil {
+		t.Fatalf("NewPublicKey failed: %v", err)
+	}
+	lines := x25519.Recipient().String() + "\n" + string(ssh.MarshalAuthorizedKey(sshPub))
+	recipients, err := ParseAgeRecipients([]byte(lines))
+	if err != nil {
+		t.Fatalf("ParseAgeRecipients failed: %v", err)
+	}
+	if len(recipients) != 2 {
+		t.Fatalf("expected 2 recipients, got %d", len(recipients))
+	}
+
+	r := bytes.NewReader(data)
+	layout := &FileLayout{}
+	if err = layout.ReadHeadIn(r); err != nil {
+		t.Fatalf("ReadHeadIn failed: %v", err)
+	}
+	f2 := &Fortifier{meta: layout.Metadata(), key: f.key, block: f.block}
+	var exported bytes.Buffer
+	if err = ExportAge(NewDecrypter(layout.Metadata().Mode, f2), r, &exported, layout, recipients...); err != nil {
+		t.Fatalf("ExportAge failed: %v", err)
+	}
+
+	der := x509.MarshalPKCS1PrivateKey(pri)
+	identities := map[string][]byte{
+		"x25519":  []byte(x25519.String() + "\n"),
+		"ssh-rsa": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der}),
+	}
+	for name, kb := range identities {
+		ids, err := ParseAgeIdentities(kb)
+		if err != nil {
+			t.Fatalf("%s: ParseAgeIdentities failed: %v", name, err)
+		}
+		ar, err := age.Decrypt(bytes.NewReader(exported.Bytes()), ids...)
+		if err != nil {
+			t.Fatalf("%s: age.Decrypt failed: %v", name, err)
+		}
+		decrypted, err := io.ReadAll(ar)
+		if err != nil {
+			t.Fatalf("%s: reading age payload failed: %v", name, err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Fatalf("%s: exported content mismatch", name)
+		}
+
+		var imported bytes.Buffer
+		f3 := NewFortifierWithSss(false, true, nil)
+		if err = ImportAge(NewEncrypter(CipherModeXChaCha20Poly1305, f3), bytes.NewReader(exported.Bytes()),
+			&imported, ids...); err != nil {
+			t.Fatalf("%s: ImportAge failed: %v", name, err)
+		}
+		decrypted, err = decryptBytes(f3, imported.Bytes())
+		if err != nil {
+			t.Fatalf("%s: Decrypt of imported file failed: %v", name, err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("%s: imported content mismatch", name)
+		}
+	}
+}
+
+func TestExportAge_TamperedInput(t *testing.T) {
+	f, data := encryptToBytes(t, CipherModeAes256CTR, []byte("do not export me when tampered"))
+	data[len(data)-1] ^= 0x01
+	x25519, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity failed: %v", err)
+	}
+	r := bytes.NewReader(data)
+	layout := &FileLayout{}
+	if err = layout.ReadHeadIn(r); err != nil {
+		t.Fatalf("ReadHeadIn failed: %v", err)
+	}
+	f2 := &Fortifier{meta: layout.Metadata(), key: f.key, block: f.block}
+	var exported bytes.Buffer
+	if err = ExportAge(NewDecrypter(layout.Metadata().Mode, f2), r, &exported, layout, x25519.Recipient()); err == nil {
+		t.Fatal("expected ExportAge to fail")
+	}
+	if ar, err := age.Decrypt(bytes.NewReader(exported.Bytes()), x25519); err == nil {
+		if _, err = io.ReadAll(ar); err == nil {
+			t.Error("expected the unfinished age file to be rejected")
+		}
+	}
+}
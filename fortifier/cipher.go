@@ -1,14 +1,17 @@
 package fortifier
 
 import (
+	"bufio"
 	"crypto/cipher"
 	"crypto/hmac"
 	"crypto/sha256"
 	"fmt"
 	"hash"
+	"io"
 	"os"
 
 	"github.com/i3ash/fortify/sss"
+	"golang.org/x/crypto/chacha20poly1305"
 	"golang.org/x/term"
 )
 
@@ -19,8 +22,10 @@ func (s CipherKeyKind) String() string {
 }
 
 const (
-	CipherKeyKindSSS CipherKeyKind = "sss"
-	CipherKeyKindRSA CipherKeyKind = "rsa"
+	CipherKeyKindSSS        CipherKeyKind = "sss"
+	CipherKeyKindRSA        CipherKeyKind = "rsa"
+	CipherKeyKindECDH       CipherKeyKind = "ecdh"
+	CipherKeyKindPassphrase CipherKeyKind = "passphrase"
 )
 
 type CipherKey interface {
@@ -29,10 +34,11 @@ type CipherKey interface {
 }
 
 type CipherKeyData struct {
-	kind  CipherKeyKind
-	raw   []byte
-	parts []sss.Part
-	bytes []byte
+	kind   CipherKeyKind
+	raw    []byte
+	parts  []sss.Part
+	bytes  []byte
+	others [][]byte
 }
 
 func (k *CipherKeyData) NewSha256() hash.Hash {
@@ -50,23 +56,78 @@ func (s CipherModeName) String() string {
 }
 
 type CipherMode struct {
-	Name       CipherModeName
+	Name        CipherModeName
 	StreamMaker func(block cipher.Block, iv []byte) cipher.Stream
+	AeadMaker   func(key []byte) (cipher.AEAD, error)
 }
 
 const (
-	CipherModeAes256CTR CipherModeName = "aes256-ctr"
-	CipherModeAes256OFB CipherModeName = "aes256-ofb"
-	CipherModeAes256CFB CipherModeName = "aes256-cfb"
+	CipherModeAes256CTR         CipherModeName = "aes256-ctr"
+	CipherModeAes256OFB         CipherModeName = "aes256-ofb"
+	CipherModeAes256CFB         CipherModeName = "aes256-cfb"
+	CipherModeAes256GCM         CipherModeName = "aes256-gcm-stream"
+	CipherModeXChaCha20Poly1305 CipherModeName = "xchacha20-poly1305-stream"
 )
 
+// decryptModeOf returns the cipher mode which the Decrypter of name works with
+func decryptModeOf(name CipherModeName) (CipherMode, error) {
+	switch name {
+	case CipherModeAes256CTR:
+		return CipherMode{Name: name, StreamMaker: cipher.NewCTR}, nil
+	case CipherModeAes256OFB:
+		return CipherMode{Name: name, StreamMaker: cipher.NewOFB}, nil
+	case CipherModeAes256CFB:
+		return CipherMode{Name: name, StreamMaker: cipher.NewCFBDecrypter}, nil
+	case CipherModeAes256GCM:
+		return CipherMode{Name: name, AeadMaker: newAes256GCM}, nil
+	case CipherModeXChaCha20Poly1305:
+		return CipherMode{Name: name, AeadMaker: chacha20poly1305.NewX}, nil
+	default:
+		return CipherMode{}, fmt.Errorf("unknown cipher mode name: %s", name)
+	}
+}
+
+func (m CipherMode) ivSize(block cipher.Block) int {
+	if m.AeadMaker != nil {
+		return aeadSaltSize
+	}
+	return block.BlockSize()
+}
+
+func (m CipherMode) newWriter(block cipher.Block, key, iv []byte, w io.Writer) (io.WriteCloser, error) {
+	if m.AeadMaker == nil {
+		return cipher.StreamWriter{S: m.StreamMaker(block, iv), W: w}, nil
+	}
+	aead, err := newAeadChunkCipher(m, key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return newAeadChunkWriter(aead, w), nil
+}
+
+func (m CipherMode) newReader(block cipher.Block, key, iv []byte, r *bufio.Reader) (io.Reader, error) {
+	if m.AeadMaker == nil {
+		return cipher.StreamReader{S: m.StreamMaker(block, iv), R: r}, nil
+	}
+	aead, err := newAeadChunkCipher(m, key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return newAeadChunkReader(aead, r), nil
+}
+
 func enterPassphrase() []byte {
-	fmt.Print("Enter passphrase: ")
+	return promptPassphrase("Enter passphrase: ")
+}
+
+// promptPassphrase reads a passphrase from the terminal, prompting on stderr to keep stdout clean
+func promptPassphrase(prompt string) []byte {
+	_, _ = fmt.Fprint(os.Stderr, prompt)
 	if passphrase, err := term.ReadPassword(int(os.Stdin.Fd())); err != nil {
-		fmt.Printf("\nError reading passphrase: %v\n", err)
+		_, _ = fmt.Fprintf(os.Stderr, "\nError reading passphrase: %v\n", err)
 		return nil
 	} else {
-		fmt.Println()
+		_, _ = fmt.Fprintln(os.Stderr)
 		return passphrase
 	}
 }
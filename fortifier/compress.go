@@ -0,0 +1,92 @@
+package fortifier
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionName names the compression of the data before it is encrypted.
+//
+// The data length and the checksum of a compressed fortified file cover the compressed data,
+// which is what is encrypted, so that they are verified without decompressing it.
+type CompressionName string
+
+func (s CompressionName) String() string {
+	return string(s)
+}
+
+const (
+	CompressionNone CompressionName = ""
+	CompressionGzip CompressionName = "gzip"
+	CompressionZstd CompressionName = "zstd"
+)
+
+// ParseCompression returns the compression named name, with "none" for no compression
+func ParseCompression(name string) (CompressionName, error) {
+	switch c := CompressionName(name); c {
+	case CompressionGzip, CompressionZstd:
+		return c, nil
+	case CompressionNone, "none":
+		return CompressionNone, nil
+	default:
+		return CompressionNone, fmt.Errorf("unknown compression name: %s", name)
+	}
+}
+
+// SetCompression compresses the data of a new fortified file before it is encrypted
+func (f *Fortifier) SetCompression(c CompressionName) {
+	f.meta.Compression = c
+}
+
+func newCompressWriter(c CompressionName, w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown compression name: %s", c)
+	}
+}
+
+func newDecompressReader(c CompressionName, r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression name: %s", c)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// countWriter counts the bytes written through it
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return
+}
@@ -0,0 +1,101 @@
+package fortifier
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func encryptCompressed(t *testing.T, mode CipherModeName, c CompressionName, plaintext []byte) (*Fortifier, []byte) {
+	t.Helper()
+	f := NewFortifierWithSss(false, true, nil)
+	f.SetCompression(c)
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("SetupKey failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncrypter(mode, f).Encrypt(bytes.NewReader(plaintext), &buf); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	return f, buf.Bytes()
+}
+
+func TestCompression_RoundTrip(t *testing.T) {
+	plaintext := bytes.Repeat([]byte(`{"level":"info","msg":"compress me"}`+"\n"), 5000)
+	modes := []CipherModeName{CipherModeAes256CTR, CipherModeAes256CFB, CipherModeAes256GCM, CipherModeXChaCha20Poly1305}
+	for _, c := range []CompressionName{CompressionGzip, CompressionZstd} {
+		for _, mode := range modes {
+			f, data := encryptCompressed(t, mode, c, plaintext)
+			if len(data) > len(plaintext)/4 {
+				t.Errorf("%s %s: expected compressed data, got %d bytes", c, mode, len(data))
+			}
+			layout := &FileLayout{}
+			if err := layout.ReadHeadIn(bytes.NewReader(data)); err != nil {
+				t.Fatalf("ReadHeadIn failed: %v", err)
+			}
+			if layout.Metadata().Compression != c {
+				t.Errorf("%s %s: expected compression in metadata, got %q", c, mode, layout.Metadata().Compression)
+			}
+			decrypted, err := decryptBytes(f, data)
+			if err != nil {
+				t.Fatalf("%s %s: Decrypt failed: %v", c, mode, err)
+			}
+			if !bytes.Equal(plaintext, decrypted) {
+				t.Errorf("%s %s: decrypted content mismatch", c, mode)
+			}
+		}
+	}
+}
+
+func TestCompression_DataLengthAndChecksum(t *testing.T) {
+	plaintext := bytes.Repeat([]byte("0123456789"), 10000)
+	f, data := encryptCompressed(t, CipherModeAes256CTR, CompressionZstd, plaintext)
+	r := bytes.NewReader(data)
+	layout := &FileLayout{}
+	if err := layout.ReadHeadIn(r); err != nil {
+		t.Fatalf("ReadHeadIn failed: %v", err)
+	}
+	if layout.DataLength() >= uint64(len(plaintext)) {
+		t.Errorf("expected the data length of the compressed data, got %d", layout.DataLength())
+	}
+	// The checksum covers the compressed data, so it is verified without decompressing
+	f2 := &Fortifier{meta: layout.Metadata(), key: f.key, block: f.block}
+	if err := NewDecrypter(CipherModeAes256CTR, f2).Decrypt(r, nil, layout); err != nil {
+		t.Errorf("Decrypt without output failed: %v", err)
+	}
+
+	data[len(data)-layoutTrailerSize-10] ^= 0x01
+	if _, err := decryptBytes(f, data); !errors.Is(err, ErrInvalidChecksum) {
+		t.Errorf("expected %v for tampered data, got %v", ErrInvalidChecksum, err)
+	}
+}
+
+func TestOpenReader_RejectsCompressed(t *testing.T) {
+	f, data := encryptCompressed(t, CipherModeAes256CTR, CompressionGzip, []byte("not seekable"))
+	path := filepath.Join(t.TempDir(), "fortified.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write fortified file: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open fortified file: %v", err)
+	}
+	defer file.Close()
+	if _, err = OpenReader(file, f, false); err == nil {
+		t.Error("expected error for compressed data")
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	for name, expect := range map[string]CompressionName{"": CompressionNone, "none": CompressionNone,
+		"gzip": CompressionGzip, "zstd": CompressionZstd} {
+		if c, err := ParseCompression(name); err != nil || c != expect {
+			t.Errorf("%q: expected %q, got %q, %v", name, expect, c, err)
+		}
+	}
+	if _, err := ParseCompression("lz4"); err == nil {
+		t.Error("expected error for unknown compression")
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"io"
 	"os"
 	"time"
+
+	"github.com/i3ash/fortify/sss"
 )
 
 type Encrypter interface {
+	Encrypt(r io.Reader, w io.Writer) error
 	EncryptFile(in, out *os.File) error
 }
 
@@ -18,19 +21,35 @@ type Decrypter interface {
 }
 
 type Metadata struct {
-	Timestamp time.Time      `json:"timestamp"`
-	Key       CipherKeyKind  `json:"key"`
-	Mode      CipherModeName `json:"mode"`
-	Sss       *MetadataSss   `json:"sss"`
-	Rsa       *MetadataRsa   `json:"rsa"`
+	Timestamp   time.Time           `json:"timestamp"`
+	Key         CipherKeyKind       `json:"key"`
+	Mode        CipherModeName      `json:"mode"`
+	Sss         *MetadataSss        `json:"sss"`
+	Rsa         *MetadataRsa        `json:"rsa"`
+	Recipients  []*MetadataRsa      `json:"recipients,omitempty"`
+	Ecdh        *MetadataEcdh       `json:"ecdh,omitempty"`
+	Passphrase  *MetadataPassphrase `json:"passphrase,omitempty"`
+	Content     ContentKind         `json:"content,omitempty"`
+	Compression CompressionName     `json:"compression,omitempty"`
+}
+
+// ContentKind tells what the decrypted data of a fortified file is, a single file if empty
+type ContentKind string
+
+func (s ContentKind) String() string {
+	return string(s)
 }
 
+// ContentKindTar is a tar archive of a directory tree
+const ContentKindTar ContentKind = "tar"
+
 type Fortifier struct {
 	meta     *Metadata
 	key      *CipherKeyData
 	verbose  bool
 	truncate bool
 	block    cipher.Block
+	ignored  func([]*sss.ShareError) // Told the secret shares which the key is recovered without
 }
 
 func NewEncrypter(mode CipherModeName, f *Fortifier) Encrypter {
@@ -41,6 +60,10 @@ func NewEncrypter(mode CipherModeName, f *Fortifier) Encrypter {
 		return NewAes256EncrypterOFB(f)
 	case CipherModeAes256CFB:
 		return NewAes256EncrypterCFB(f)
+	case CipherModeAes256GCM:
+		return NewAes256EncrypterGCM(f)
+	case CipherModeXChaCha20Poly1305:
+		return NewXChaCha20EncrypterPoly1305(f)
 	default:
 		return nil
 	}
@@ -54,6 +77,10 @@ func NewDecrypter(mode CipherModeName, f *Fortifier) Decrypter {
 		return NewAes256DecrypterOFB(f)
 	case CipherModeAes256CFB:
 		return NewAes256DecrypterCFB(f)
+	case CipherModeAes256GCM:
+		return NewAes256DecrypterGCM(f)
+	case CipherModeXChaCha20Poly1305:
+		return NewXChaCha20DecrypterPoly1305(f)
 	default:
 		return nil
 	}
@@ -66,6 +93,10 @@ func (f *Fortifier) SetupKey() (err error) {
 	switch f.key.kind {
 	case CipherKeyKindRSA:
 		err = f.setupRsaKey()
+	case CipherKeyKindECDH:
+		err = f.setupEcdhKey()
+	case CipherKeyKindPassphrase:
+		err = f.setupPassphraseKey()
 	default:
 		err = f.setupSssKey()
 	}
@@ -77,3 +108,40 @@ func (f *Fortifier) SetupKey() (err error) {
 	}
 	return
 }
+
+// Clone returns a copy of f with its own metadata, which shares the key once it is set up,
+// so that many files are encrypted or decrypted concurrently under one key.
+func (f *Fortifier) Clone() *Fortifier {
+	c := *f
+	meta := *f.meta
+	c.meta = &meta
+	return &c
+}
+
+// KeyDigest returns the digest of the data key recorded in the metadata, or an empty string if there is none
+func (m *Metadata) KeyDigest() string {
+	switch {
+	case m.Key == CipherKeyKindRSA && m.Rsa != nil:
+		return m.Rsa.Digest
+	case m.Key == CipherKeyKindECDH && m.Ecdh != nil:
+		return m.Ecdh.Digest
+	case m.Key == CipherKeyKindPassphrase && m.Passphrase != nil:
+		return m.Passphrase.Digest
+	case m.Sss != nil:
+		return m.Sss.Digest
+	}
+	return ""
+}
+
+// SetContent records what the data of a new fortified file is
+func (f *Fortifier) SetContent(content ContentKind) {
+	f.meta.Content = content
+}
+
+// VerifyHead sets up the key and checks the head checksum of layout, without reading the data
+func (f *Fortifier) VerifyHead(layout *FileLayout) (err error) {
+	if err = f.SetupKey(); err != nil {
+		return
+	}
+	return (&Aes256StreamDecrypter{f}).verifyHead(layout)
+}
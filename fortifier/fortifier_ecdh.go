@@ -0,0 +1,260 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"filippo.io/edwards25519"
+	"github.com/i3ash/fortify/utils"
+)
+
+const ecdhFortifier = "ecdh_fortifier"
+
+const (
+	ecdhCurveX25519 = "x25519"
+	ecdhCurveP256   = "p256"
+)
+
+type MetadataEcdh struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Digest      string    `json:"digest"`
+	Curve       string    `json:"curve"`
+	Ephemeral   string    `json:"ephemeral"`
+	Ciphertext  string    `json:"ciphertext"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+}
+
+// NewFortifierWithEcdh wraps the data key with an ECIES style key agreement,
+// for X25519 and P-256 keys as well as ssh-ed25519 keys converted to X25519.
+func NewFortifierWithEcdh(verbose bool, meta *Metadata, bytes []byte) *Fortifier {
+	var m *MetadataEcdh
+	if meta != nil {
+		m = meta.Ecdh
+	}
+	return &Fortifier{
+		meta:    &Metadata{Ecdh: m},
+		key:     &CipherKeyData{kind: CipherKeyKindECDH, bytes: bytes},
+		verbose: verbose,
+	}
+}
+
+func (f *Fortifier) setupEcdhKey() error {
+	if f.meta.Ecdh == nil {
+		return f.setupEcdhPublicKey()
+	} else {
+		return f.setupEcdhPrivateKey()
+	}
+}
+
+func (f *Fortifier) setupEcdhPublicKey() (err error) {
+	var k any
+	if k, err = parsePublicKey(ecdhFortifier, f.key.bytes); err != nil {
+		return
+	}
+	var pub *ecdh.PublicKey
+	if pub, err = toEcdhPublicKey(k); err != nil {
+		return
+	}
+	var eph *ecdh.PrivateKey
+	if eph, err = pub.Curve().GenerateKey(rand.Reader); err != nil {
+		return
+	}
+	raw := f.key.raw
+	if len(raw) == 0 {
+		raw = make([]byte, 32)
+		if _, err = rand.Read(raw); err != nil {
+			return
+		}
+	}
+	var aead cipher.AEAD
+	if aead, err = newEcdhKeyWrapper(eph, pub); err != nil {
+		return
+	}
+	sealed := aead.Seal(nil, make([]byte, aead.NonceSize()), raw, nil)
+	f.key.raw = raw
+	f.meta.Key = CipherKeyKindECDH
+	f.meta.Timestamp = time.Now()
+	f.meta.Ecdh = &MetadataEcdh{
+		Timestamp:   time.Now(),
+		Digest:      utils.ComputeDigest(raw),
+		Curve:       ecdhCurveName(pub.Curve()),
+		Ephemeral:   base64.URLEncoding.EncodeToString(eph.PublicKey().Bytes()),
+		Ciphertext:  base64.URLEncoding.EncodeToString(sealed),
+		Fingerprint: ecdhFingerprint(pub),
+	}
+	if f.meta.Sss != nil {
+		return f.splitSssKey()
+	}
+	return
+}
+
+func (f *Fortifier) setupEcdhPrivateKey() (err error) {
+	var k any
+	if k, err = parsePrivateKey(ecdhFortifier, f.key.bytes); err != nil {
+		return
+	}
+	var pri *ecdh.PrivateKey
+	if pri, err = toEcdhPrivateKey(k); err != nil {
+		return
+	}
+	m := f.meta.Ecdh
+	if curve := ecdhCurveName(pri.Curve()); curve != m.Curve {
+		return fmt.Errorf("%s: requiring a %s private key, not %s", ecdhFortifier, m.Curve, curve)
+	}
+	var eph *ecdh.PublicKey
+	var ephBytes, sealed []byte
+	if ephBytes, err = base64.URLEncoding.DecodeString(m.Ephemeral); err != nil {
+		return
+	}
+	if eph, err = pri.Curve().NewPublicKey(ephBytes); err != nil {
+		return
+	}
+	if sealed, err = base64.URLEncoding.DecodeString(m.Ciphertext); err != nil {
+		return
+	}
+	var aead cipher.AEAD
+	if aead, err = newEcdhKeyUnwrapper(pri, eph); err != nil {
+		return
+	}
+	if f.key.raw, err = aead.Open(nil, make([]byte, aead.NonceSize()), sealed, nil); err != nil {
+		return fmt.Errorf("%s: decrypting secret key failed, private key %s mismatched", ecdhFortifier,
+			ecdhFingerprint(pri.PublicKey()))
+	}
+	actual := utils.ComputeDigest(f.key.raw)
+	if m.Digest != actual {
+		return fmt.Errorf("%s: digest mismatch. expect %q, actual %q", ecdhFortifier, m.Digest, actual)
+	}
+	return
+}
+
+func newEcdhKeyWrapper(eph *ecdh.PrivateKey, pub *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := eph.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	return newEcdhKeyCipher(shared, eph.PublicKey(), pub)
+}
+
+func newEcdhKeyUnwrapper(pri *ecdh.PrivateKey, eph *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := pri.ECDH(eph)
+	if err != nil {
+		return nil, err
+	}
+	return newEcdhKeyCipher(shared, eph, pri.PublicKey())
+}
+
+// newEcdhKeyCipher derives a key encryption key bound to both public keys.
+// The ephemeral key is never reused, so the key is sealed under a zero nonce.
+func newEcdhKeyCipher(shared []byte, eph, pub *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := slices.Concat(eph.Bytes(), pub.Bytes())
+	kek, err := hkdf.Key(sha256.New, shared, salt, ecdhFortifier, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func toEcdhPublicKey(k any) (*ecdh.PublicKey, error) {
+	switch key := k.(type) {
+	case *ecdh.PublicKey:
+		return checkEcdhCurve(key)
+	case *ecdsa.PublicKey:
+		pub, err := key.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ecdhFortifier, err)
+		}
+		return checkEcdhCurve(pub)
+	case ed25519.PublicKey:
+		return ed25519PublicKeyToX25519(key)
+	}
+	return nil, fmt.Errorf("%s: unsupported public key %v", ecdhFortifier, reflect.TypeOf(k))
+}
+
+func toEcdhPrivateKey(k any) (*ecdh.PrivateKey, error) {
+	switch key := k.(type) {
+	case *ecdh.PrivateKey:
+		if _, err := checkEcdhCurve(key.PublicKey()); err != nil {
+			return nil, err
+		}
+		return key, nil
+	case *ecdsa.PrivateKey:
+		pri, err := key.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", ecdhFortifier, err)
+		}
+		if _, err = checkEcdhCurve(pri.PublicKey()); err != nil {
+			return nil, err
+		}
+		return pri, nil
+	case ed25519.PrivateKey:
+		return ed25519PrivateKeyToX25519(key)
+	case *ed25519.PrivateKey:
+		return ed25519PrivateKeyToX25519(*key)
+	}
+	return nil, fmt.Errorf("%s: unsupported private key %v", ecdhFortifier, reflect.TypeOf(k))
+}
+
+func checkEcdhCurve(pub *ecdh.PublicKey) (*ecdh.PublicKey, error) {
+	if ecdhCurveName(pub.Curve()) == "" {
+		return nil, fmt.Errorf("%s: unsupported curve %v", ecdhFortifier, pub.Curve())
+	}
+	return pub, nil
+}
+
+func ecdhCurveName(curve ecdh.Curve) string {
+	switch curve {
+	case ecdh.X25519():
+		return ecdhCurveX25519
+	case ecdh.P256():
+		return ecdhCurveP256
+	}
+	return ""
+}
+
+func ecdhFingerprint(pub *ecdh.PublicKey) string {
+	sum := sha256.Sum256(pub.Bytes())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// ed25519PrivateKeyToX25519 takes the clamped scalar of an Ed25519 key as an X25519 private key
+func ed25519PrivateKeyToX25519(key ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	h := sha512.Sum512(key.Seed())
+	return ecdh.X25519().NewPrivateKey(h[:32])
+}
+
+// ed25519PublicKeyToX25519 maps an Edwards point to the Montgomery u = (1 + y) / (1 - y),
+// refusing the encodings which are not canonical or not on the curve, and the points of small order
+func ed25519PublicKeyToX25519(key ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid length of ed25519 public key")
+	}
+	p, err := new(edwards25519.Point).SetBytes(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ed25519 public key: %w", err)
+	}
+	// SetBytes takes the encodings of y beyond the field as well, which a key never has
+	if !slices.Equal(p.Bytes(), key) {
+		return nil, errors.New("invalid ed25519 public key: non-canonical encoding")
+	}
+	if new(edwards25519.Point).MultByCofactor(p).Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return nil, errors.New("invalid ed25519 public key: point of small order")
+	}
+	return ecdh.X25519().NewPublicKey(p.BytesMontgomery())
+}
@@ -0,0 +1,107 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/i3ash/fortify/utils"
+	"golang.org/x/crypto/argon2"
+)
+
+const passphraseFortifier = "passphrase_fortifier"
+
+const (
+	DefaultArgon2Time    uint32 = 3
+	DefaultArgon2Memory  uint32 = 64 * 1024
+	DefaultArgon2Threads uint8  = 4
+	MaxArgon2Time        uint32 = 64
+	MaxArgon2Memory      uint32 = 1024 * 1024
+)
+
+type MetadataPassphrase struct {
+	Timestamp time.Time `json:"timestamp"`
+	Digest    string    `json:"digest"`
+	Salt      string    `json:"salt"`
+	Time      uint32    `json:"time"`
+	Memory    uint32    `json:"memory"`
+	Threads   uint8     `json:"threads"`
+}
+
+// NewFortifierWithPassphrase derives the data key from passphrase with Argon2id.
+// The passphrase is read from the terminal if it is empty.
+func NewFortifierWithPassphrase(verbose bool, meta *Metadata, passphrase []byte) *Fortifier {
+	var m *MetadataPassphrase
+	if meta != nil {
+		m = meta.Passphrase
+	}
+	return &Fortifier{
+		meta:    &Metadata{Passphrase: m},
+		key:     &CipherKeyData{kind: CipherKeyKindPassphrase, bytes: passphrase},
+		verbose: verbose,
+	}
+}
+
+// SetArgon2Cost sets the time cost, the memory cost in KiB and the parallelism of a new passphrase key
+func (f *Fortifier) SetArgon2Cost(time, memory uint32, threads uint8) {
+	f.meta.Passphrase = &MetadataPassphrase{Time: time, Memory: memory, Threads: threads}
+}
+
+func (f *Fortifier) setupPassphraseKey() (err error) {
+	m := f.meta.Passphrase
+	if m == nil {
+		m = &MetadataPassphrase{Time: DefaultArgon2Time, Memory: DefaultArgon2Memory, Threads: DefaultArgon2Threads}
+		f.meta.Passphrase = m
+	}
+	if m.Time < 1 || m.Threads < 1 {
+		return fmt.Errorf("%s: time cost and threads must be at least 1", passphraseFortifier)
+	}
+	// The costs are read from the metadata of the fortified file, which must not exhaust the resources of its reader
+	if m.Time > MaxArgon2Time || m.Memory > MaxArgon2Memory {
+		return fmt.Errorf("%s: time cost %d or memory cost %d KiB exceeds the limit of %d passes and %d KiB",
+			passphraseFortifier, m.Time, m.Memory, MaxArgon2Time, MaxArgon2Memory)
+	}
+	fresh := m.Salt == ""
+	passphrase := f.key.bytes
+	if len(passphrase) == 0 {
+		if passphrase = enterPassphrase(); fresh && len(passphrase) > 0 {
+			if !bytes.Equal(passphrase, promptPassphrase("Confirm passphrase: ")) {
+				return fmt.Errorf("%s: passphrases do not match", passphraseFortifier)
+			}
+		}
+	}
+	if len(passphrase) == 0 {
+		return fmt.Errorf("%s: empty passphrase", passphraseFortifier)
+	}
+	var salt []byte
+	if fresh {
+		salt = make([]byte, 16)
+		if _, err = rand.Read(salt); err != nil {
+			return
+		}
+	} else if salt, err = base64.URLEncoding.DecodeString(m.Salt); err != nil {
+		return
+	}
+	raw := argon2.IDKey(passphrase, salt, m.Time, m.Memory, m.Threads, 32)
+	digest := utils.ComputeDigest(raw)
+	if !fresh {
+		if m.Digest != digest {
+			return errors.New(passphraseFortifier + ": wrong passphrase")
+		}
+		f.key.raw = raw
+		return
+	}
+	f.key.raw = raw
+	f.meta.Key = CipherKeyKindPassphrase
+	f.meta.Timestamp = time.Now()
+	m.Timestamp = time.Now()
+	m.Digest = digest
+	m.Salt = base64.URLEncoding.EncodeToString(salt)
+	if f.meta.Sss != nil {
+		return f.splitSssKey()
+	}
+	return
+}
@@ -21,19 +21,23 @@ import (
 const rsaFortifier = "rsa_fortifier"
 
 type MetadataRsa struct {
-	Timestamp  time.Time `json:"timestamp"`
-	Digest     string    `json:"digest"`
-	Ciphertext string    `json:"ciphertext"`
+	Timestamp   time.Time `json:"timestamp"`
+	Digest      string    `json:"digest"`
+	Ciphertext  string    `json:"ciphertext"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
 }
 
-func NewFortifierWithRsa(verbose bool, meta *Metadata, bytes []byte) *Fortifier {
-	var m *MetadataRsa
+// NewFortifierWithRsa wraps the data key for the public key in bytes and every public key in more,
+// so that any one of the matching private keys can decrypt.
+func NewFortifierWithRsa(verbose bool, meta *Metadata, bytes []byte, more ...[]byte) *Fortifier {
+	m := &Metadata{}
 	if meta != nil {
-		m = meta.Rsa
+		m.Rsa = meta.Rsa
+		m.Recipients = meta.Recipients
 	}
 	return &Fortifier{
-		meta:    &Metadata{Rsa: m},
-		key:     &CipherKeyData{kind: CipherKeyKindRSA, bytes: bytes},
+		meta:    m,
+		key:     &CipherKeyData{kind: CipherKeyKindRSA, bytes: bytes, others: more},
 		verbose: verbose,
 	}
 }
@@ -47,59 +51,104 @@ func (f *Fortifier) setupRsaKey() error {
 }
 
 func (f *Fortifier) setupRsaPublicKey() (err error) {
-	var pub *rsa.PublicKey
-	parsed, _, _, _, x := ssh.ParseAuthorizedKey(f.key.bytes)
+	keys := append([][]byte{f.key.bytes}, f.key.others...)
+	pubs := make([]*rsa.PublicKey, len(keys))
+	for i, kb := range keys {
+		if pubs[i], err = parseRsaPublicKey(kb); err != nil {
+			return
+		}
+	}
+	raw := f.key.raw
+	if len(raw) == 0 {
+		raw = make([]byte, 32)
+		if _, err = rand.Read(raw); err != nil {
+			return
+		}
+	}
+	f.meta.Rsa = nil
+	f.meta.Recipients = nil
+	for _, pub := range pubs {
+		var m *MetadataRsa
+		if m, err = wrapRsaKey(pub, raw); err != nil {
+			return
+		}
+		if f.meta.Rsa == nil {
+			f.meta.Rsa = m
+		} else {
+			f.meta.Recipients = append(f.meta.Recipients, m)
+		}
+	}
+	f.key.raw = raw
+	f.meta.Key = CipherKeyKindRSA
+	f.meta.Timestamp = time.Now()
+	if f.meta.Sss != nil {
+		return f.splitSssKey()
+	}
+	return
+}
+
+func parseRsaPublicKey(kb []byte) (*rsa.PublicKey, error) {
+	k, err := parsePublicKey(rsaFortifier, kb)
+	if err != nil {
+		return nil, err
+	}
+	if pub, ok := k.(*rsa.PublicKey); !ok {
+		return nil, fmt.Errorf("%s: requiring *rsa.PublicKey, not %v", rsaFortifier, reflect.TypeOf(k))
+	} else {
+		return pub, nil
+	}
+}
+
+// parsePublicKey parses an OpenSSH authorized key, an SSH2 public key or a PEM encoded public key
+func parsePublicKey(name string, kb []byte) (k any, err error) {
+	parsed, _, _, _, x := ssh.ParseAuthorizedKey(kb)
 	if x != nil {
-		parsed, err = ParseSSH2PublicKey(string(f.key.bytes))
+		parsed, err = ParseSSH2PublicKey(string(kb))
 	}
 	if parsed != nil {
 		if parsedCryptoKey, ok := parsed.(ssh.CryptoPublicKey); ok {
-			k := parsedCryptoKey.CryptoPublicKey()
-			pub, _ = k.(*rsa.PublicKey)
+			return parsedCryptoKey.CryptoPublicKey(), nil
 		}
 	}
-	if pub == nil {
-		blocks := f.decodePemFile()
-		if len(blocks) == 0 {
-			return fmt.Errorf("%s: pem file decoding failed", rsaFortifier)
-		}
-		block := &blocks[0]
-		var k any
-		switch block.Type {
-		case "RSA PUBLIC KEY":
-			if k, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
-				return fmt.Errorf("%s: not public key in PKCS #1, ASN.1 DER form -- %v", rsaFortifier, err)
-			}
-		case "PUBLIC KEY":
-			if k, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
-				return fmt.Errorf("%s: error parsing PKCS#8 public key -- %v", rsaFortifier, err)
-			}
+	blocks := decodePemBlocks(kb)
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("%s: pem file decoding failed", name)
+	}
+	block := &blocks[0]
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		if k, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("%s: not public key in PKCS #1, ASN.1 DER form -- %v", name, err)
 		}
-		if k == nil {
-			return fmt.Errorf("%s: unsupported key type %q", rsaFortifier, block.Type)
+	case "PUBLIC KEY":
+		if k, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("%s: error parsing PKCS#8 public key -- %v", name, err)
 		}
-		pub = k.(*rsa.PublicKey)
-	}
-	if pub == nil {
-		return
 	}
-	raw := make([]byte, 32)
-	if _, err = rand.Read(raw); err != nil {
-		return
+	if k == nil {
+		return nil, fmt.Errorf("%s: unsupported key type %q", name, block.Type)
 	}
-	var encrypted []byte
-	if encrypted, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, raw, nil); err != nil {
-		return
+	return k, nil
+}
+
+func wrapRsaKey(pub *rsa.PublicKey, raw []byte) (*MetadataRsa, error) {
+	encrypted, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, raw, nil)
+	if err != nil {
+		return nil, err
 	}
-	f.key.raw = raw
-	f.meta.Key = CipherKeyKindRSA
-	f.meta.Timestamp = time.Now()
-	f.meta.Rsa = &MetadataRsa{
-		Timestamp:  time.Now(),
-		Digest:     utils.ComputeDigest(raw),
-		Ciphertext: base64.URLEncoding.EncodeToString(encrypted),
+	return &MetadataRsa{
+		Timestamp:   time.Now(),
+		Digest:      utils.ComputeDigest(raw),
+		Ciphertext:  base64.URLEncoding.EncodeToString(encrypted),
+		Fingerprint: rsaFingerprint(pub),
+	}, nil
+}
+
+func rsaFingerprint(pub *rsa.PublicKey) string {
+	if k, err := ssh.NewPublicKey(pub); err == nil {
+		return ssh.FingerprintSHA256(k)
 	}
-	return
+	return ""
 }
 
 func (f *Fortifier) setupRsaPrivateKey() (err error) {
@@ -107,23 +156,49 @@ func (f *Fortifier) setupRsaPrivateKey() (err error) {
 	if pri, err = f.parseRsaPrivateKey(); err != nil {
 		return
 	}
-	m := f.meta.Rsa
+	fingerprint := rsaFingerprint(&pri.PublicKey)
+	err = fmt.Errorf("%s: no recipient matches the private key %s", rsaFortifier, fingerprint)
+	for _, m := range append([]*MetadataRsa{f.meta.Rsa}, f.meta.Recipients...) {
+		if m == nil || m.Fingerprint != "" && m.Fingerprint != fingerprint {
+			continue
+		}
+		if err = f.unwrapRsaKey(pri, m); err == nil {
+			return
+		}
+	}
+	return
+}
+
+func (f *Fortifier) unwrapRsaKey(pri *rsa.PrivateKey, m *MetadataRsa) (err error) {
 	var ciphertext []byte
-	ciphertext, err = base64.URLEncoding.DecodeString(m.Ciphertext)
+	if ciphertext, err = base64.URLEncoding.DecodeString(m.Ciphertext); err != nil {
+		return
+	}
 	if f.key.raw, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, pri, ciphertext, nil); err != nil {
 		return fmt.Errorf("%s: decrypting secret key failed. %v", rsaFortifier, err)
 	}
 	actual := utils.ComputeDigest(f.key.raw)
 	if m.Digest != actual {
+		f.key.raw = nil
 		return fmt.Errorf("%s: digest mismatch. expect %q, actual %q", rsaFortifier, m.Digest, actual)
 	}
 	return
 }
 
 func (f *Fortifier) parseRsaPrivateKey() (*rsa.PrivateKey, error) {
-	var k any
-	var err error
-	bytes := f.key.bytes
+	k, err := parsePrivateKey(rsaFortifier, f.key.bytes)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := k.(*rsa.PrivateKey); !ok {
+		return nil, fmt.Errorf("%s: requiring *rsa.PrivateKey, not %v", rsaFortifier, reflect.TypeOf(k))
+	} else {
+		return key, nil
+	}
+}
+
+// parsePrivateKey parses an OpenSSH or PEM encoded private key, asking for the passphrase if it is encrypted
+func parsePrivateKey(name string, bytes []byte) (k any, err error) {
 	if k, err = ssh.ParseRawPrivateKey(bytes); err != nil {
 		var passphraseMissingError *ssh.PassphraseMissingError
 		if errors.As(err, &passphraseMissingError) {
@@ -131,7 +206,7 @@ func (f *Fortifier) parseRsaPrivateKey() (*rsa.PrivateKey, error) {
 		}
 	}
 	if err != nil {
-		blocks := f.decodePemFile()
+		blocks := decodePemBlocks(bytes)
 		if len(blocks) == 0 {
 			return nil, err
 		}
@@ -142,7 +217,7 @@ func (f *Fortifier) parseRsaPrivateKey() (*rsa.PrivateKey, error) {
 			var decrypted []byte
 			decrypted, err = pkcs8.DecryptPEMBlock(block, passphrase)
 			if err != nil {
-				return nil, fmt.Errorf("%s: decrypt PKCS #8 private key failed", rsaFortifier)
+				return nil, fmt.Errorf("%s: decrypt PKCS #8 private key failed", name)
 			}
 			if k, err = x509.ParsePKCS8PrivateKey(decrypted); err != nil {
 				return nil, err
@@ -152,15 +227,10 @@ func (f *Fortifier) parseRsaPrivateKey() (*rsa.PrivateKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	if key, ok := k.(*rsa.PrivateKey); !ok {
-		return nil, fmt.Errorf("%s: requiring *rsa.PrivateKey, not %v", rsaFortifier, reflect.TypeOf(k))
-	} else {
-		return key, nil
-	}
+	return k, nil
 }
 
-func (f *Fortifier) decodePemFile() (blocks []pem.Block) {
-	kb := f.key.bytes
+func decodePemBlocks(kb []byte) (blocks []pem.Block) {
 	for {
 		var blk *pem.Block
 		blk, kb = pem.Decode(kb)
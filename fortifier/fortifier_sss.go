@@ -34,30 +34,53 @@ func NewFortifierWithSss(verbose, truncate bool, parts []sss.Part) *Fortifier {
 	}
 }
 
+// OnIgnoredShares makes f tell fn the errors of the secret shares which it drops or ignores when it recovers
+// the key from them, as they fail their commitments or disagree with the others, which are not told otherwise
+func (f *Fortifier) OnIgnoredShares(fn func([]*sss.ShareError)) {
+	f.ignored = fn
+}
+
+// SplitKey makes the data key also recoverable from a new set of secret shares,
+// which are written to fortified.key*.json files once the key is set up.
+func (f *Fortifier) SplitKey(parts, threshold uint8, truncate bool) {
+	f.meta.Sss = &MetadataSss{Parts: parts, Threshold: threshold}
+	f.truncate = truncate
+}
+
 func (f *Fortifier) setupSssKey() (err error) {
 	f.meta.Key = CipherKeyKindSSS
 	f.meta.Timestamp = time.Now()
 	if len(f.key.parts) > 0 {
-		if f.key.raw, err = sss.Combine(f.key.parts); err != nil {
+		var ignored []*sss.ShareError
+		if f.key.raw, ignored, err = sss.Combine(f.key.parts); err != nil {
 			return
 		}
-	} else {
-		f.key.raw = make([]byte, 32)
-		raw := f.key.raw
-		meta := f.meta
-		if _, err = rand.Read(raw); err != nil {
-			return
-		}
-		var ps []sss.Part
-		if ps, err = sss.Split(raw, meta.Sss.Parts, meta.Sss.Threshold); err != nil {
-			return
+		if len(ignored) > 0 && f.ignored != nil {
+			f.ignored(ignored)
 		}
-		if err = sss.AppendParts(ps, 0, 1, "fortified.key", f.truncate); err != nil {
-			return
+	} else {
+		if len(f.key.raw) == 0 {
+			f.key.raw = make([]byte, 32)
+			if _, err = rand.Read(f.key.raw); err != nil {
+				return
+			}
 		}
-		defer sss.CloseAllFilesForWrite()
-		meta.Sss.Digest = ps[0].Digest
-		meta.Sss.Timestamp = ps[0].Timestamp
+		err = f.splitSssKey()
+	}
+	return
+}
+
+func (f *Fortifier) splitSssKey() (err error) {
+	meta := f.meta
+	var ps []sss.Part
+	if ps, err = sss.Split(f.key.raw, meta.Sss.Parts, meta.Sss.Threshold); err != nil {
+		return
+	}
+	defer sss.CloseAllFilesForWrite()
+	if err = sss.AppendParts(ps, 0, 1, "fortified.key", sss.EncodingJson, f.truncate); err != nil {
+		return
 	}
+	meta.Sss.Digest = ps[0].Digest
+	meta.Sss.Timestamp = ps[0].Timestamp
 	return
 }
@@ -62,6 +62,23 @@ func TestFileLayout_WriteReadRoundTrip(t *testing.T) {
 	}
 }
 
+func TestFileLayout_NullRecipient(t *testing.T) {
+	// A crafted file may list a null recipient besides the rsa one
+	layout := &FileLayout{metadata: &Metadata{
+		Key:        CipherKeyKindRSA,
+		Mode:       CipherModeAes256CTR,
+		Rsa:        &MetadataRsa{},
+		Recipients: []*MetadataRsa{nil},
+	}}
+	var buf bytes.Buffer
+	if err := layout.WriteHeadOut(&buf); err != nil {
+		t.Fatalf("WriteHeadOut failed: %v", err)
+	}
+	if err := (&FileLayout{}).ReadHeadIn(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("expected error for a null recipient")
+	}
+}
+
 func TestFileLayout_MagicNumber(t *testing.T) {
 	layout := &FileLayout{
 		metadata: &Metadata{Key: CipherKeyKindSSS, Mode: CipherModeAes256CTR},
@@ -199,6 +216,8 @@ func TestEncryptDecrypt_AllModes(t *testing.T) {
 		{CipherModeAes256CTR, cipher.NewCTR},
 		{CipherModeAes256CFB, cipher.NewCFBEncrypter},
 		{CipherModeAes256OFB, cipher.NewOFB},
+		{CipherModeAes256GCM, nil},
+		{CipherModeXChaCha20Poly1305, nil},
 	}
 
 	for _, mode := range modes {
@@ -235,6 +254,10 @@ func TestEncryptDecrypt_AllModes(t *testing.T) {
 				enc = NewAes256EncrypterCFB(f)
 			case CipherModeAes256OFB:
 				enc = NewAes256EncrypterOFB(f)
+			case CipherModeAes256GCM:
+				enc = NewAes256EncrypterGCM(f)
+			case CipherModeXChaCha20Poly1305:
+				enc = NewXChaCha20EncrypterPoly1305(f)
 			}
 			if enc == nil {
 				t.Fatal("NewEncrypter returned nil")
@@ -278,6 +301,10 @@ func TestEncryptDecrypt_AllModes(t *testing.T) {
 				dec = NewAes256DecrypterCFB(f2)
 			case CipherModeAes256OFB:
 				dec = NewAes256DecrypterOFB(f2)
+			case CipherModeAes256GCM:
+				dec = NewAes256DecrypterGCM(f2)
+			case CipherModeXChaCha20Poly1305:
+				dec = NewXChaCha20DecrypterPoly1305(f2)
 			}
 			if dec == nil {
 				t.Fatal("NewDecrypter returned nil")
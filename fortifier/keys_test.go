@@ -3,12 +3,20 @@ package fortifier
 import (
 	"bytes"
 	"crypto/aes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/i3ash/fortify/sss"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestNewFortifierWithSss_CustomParts(t *testing.T) {
@@ -62,6 +70,74 @@ func TestSetupSssKey_WithParts(t *testing.T) {
 	}
 }
 
+func TestSetupSssKey_RefreshedParts(t *testing.T) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+	parts, err := sss.Split(secret, 3, 2)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	received := make([][]sss.Update, len(parts))
+	for _, holder := range parts[:2] {
+		updates, err := sss.NewUpdates(&holder)
+		if err != nil {
+			t.Fatalf("NewUpdates failed: %v", err)
+		}
+		for i := range updates {
+			received[i] = append(received[i], updates[i])
+		}
+	}
+	var refreshed []sss.Part
+	for i := 1; i < len(parts); i++ {
+		p, err := parts[i].Apply(received[i])
+		if err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		refreshed = append(refreshed, *p)
+	}
+
+	// The refreshed parts unlock files fortified with the old ones, whose digest they keep
+	f := NewFortifierWithSss(false, true, refreshed)
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("SetupKey failed: %v", err)
+	}
+	if !bytes.Equal(f.key.raw, secret) {
+		t.Errorf("key mismatch: got %x, expected %x", f.key.raw, secret)
+	}
+	if f.meta.Sss.Digest != parts[0].Digest {
+		t.Error("digest mismatch")
+	}
+}
+
+func TestSetupSssKey_IgnoredShares(t *testing.T) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+	parts, err := sss.SplitVerifiable(secret, 4, 2)
+	if err != nil {
+		t.Fatalf("SplitVerifiable failed: %v", err)
+	}
+	// One holder changed the commitments the others agree on
+	parts[1].Commitments = append([]string{}, parts[1].Commitments...)
+	parts[1].Commitments[0] = parts[1].Commitments[1]
+
+	f := NewFortifierWithSss(false, true, parts)
+	var ignored []*sss.ShareError
+	f.OnIgnoredShares(func(errs []*sss.ShareError) { ignored = append(ignored, errs...) })
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("SetupKey failed: %v", err)
+	}
+	if !bytes.Equal(f.key.raw, secret) {
+		t.Errorf("key mismatch: got %x, expected %x", f.key.raw, secret)
+	}
+	if len(ignored) != 1 || ignored[0].Index != 1 {
+		t.Errorf("expected the second share to be ignored, got %v", ignored)
+	}
+}
+
 func TestSetupSssKey_WithParts_NotEnoughShares(t *testing.T) {
 	secret := []byte("test-secret-32-bytes-len!!")
 	parts, err := sss.Split(secret, 3, 3) // threshold 3
@@ -120,6 +196,147 @@ func TestNewFortifierWithRsa_Basic(t *testing.T) {
 	}
 }
 
+func TestSetupRsaKey_MultipleRecipients(t *testing.T) {
+	var pris []*rsa.PrivateKey
+	var pubs [][]byte
+	for i := 0; i < 3; i++ {
+		pri, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatalf("GenerateKey failed: %v", err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(&pri.PublicKey)
+		if err != nil {
+			t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+		}
+		pris = append(pris, pri)
+		pubs = append(pubs, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	}
+	f := NewFortifierWithRsa(false, nil, pubs[0], pubs[1:]...)
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("SetupKey failed: %v", err)
+	}
+	if f.meta.Rsa == nil || len(f.meta.Recipients) != 2 {
+		t.Fatalf("expected 1 + 2 recipients, got %v and %d", f.meta.Rsa, len(f.meta.Recipients))
+	}
+	for i, pri := range pris {
+		der, err := x509.MarshalPKCS8PrivateKey(pri)
+		if err != nil {
+			t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+		}
+		kb := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		f2 := NewFortifierWithRsa(false, f.meta, kb)
+		if err = f2.SetupKey(); err != nil {
+			t.Fatalf("recipient %d: SetupKey failed: %v", i, err)
+		}
+		if !bytes.Equal(f.key.raw, f2.key.raw) {
+			t.Errorf("recipient %d: data key mismatch", i)
+		}
+	}
+}
+
+func TestEd25519ToX25519_Consistency(t *testing.T) {
+	pub, pri, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	xPub, err := ed25519PublicKeyToX25519(pub)
+	if err != nil {
+		t.Fatalf("ed25519PublicKeyToX25519 failed: %v", err)
+	}
+	xPri, err := ed25519PrivateKeyToX25519(pri)
+	if err != nil {
+		t.Fatalf("ed25519PrivateKeyToX25519 failed: %v", err)
+	}
+	if !xPub.Equal(xPri.PublicKey()) {
+		t.Error("converted public key does not match the converted private key")
+	}
+	// y = 2^255 - 1 is not canonical, y = 2 is not on the curve, and y = 1 is the identity
+	nonCanonical := bytes.Repeat([]byte{0xFF}, ed25519.PublicKeySize)
+	nonCanonical[31] = 0x7F
+	offCurve := make([]byte, ed25519.PublicKeySize)
+	offCurve[0] = 2
+	identity := make([]byte, ed25519.PublicKeySize)
+	identity[0] = 1
+	for _, key := range [][]byte{nonCanonical, offCurve, identity} {
+		if _, err = ed25519PublicKeyToX25519(key); err == nil {
+			t.Errorf("expected error for ed25519 public key %x", key)
+		}
+	}
+}
+
+func TestSetupEcdhKey_SshKeys(t *testing.T) {
+	edPub, edPri, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	ecPri, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	keys := []struct {
+		pub any
+		pri any
+	}{{edPub, edPri}, {&ecPri.PublicKey, ecPri}}
+	for _, k := range keys {
+		sshPub, err := ssh.NewPublicKey(k.pub)
+		if err != nil {
+			t.Fatalf("NewPublicKey failed: %v", err)
+		}
+		block, err := ssh.MarshalPrivateKey(k.pri, "")
+		if err != nil {
+			t.Fatalf("MarshalPrivateKey failed: %v", err)
+		}
+		f := NewFortifierWithEcdh(false, nil, ssh.MarshalAuthorizedKey(sshPub))
+		if err = f.SetupKey(); err != nil {
+			t.Fatalf("%s: SetupKey with public key failed: %v", sshPub.Type(), err)
+		}
+		f2 := NewFortifierWithEcdh(false, f.meta, pem.EncodeToMemory(block))
+		if err = f2.SetupKey(); err != nil {
+			t.Fatalf("%s: SetupKey with private key failed: %v", sshPub.Type(), err)
+		}
+		if !bytes.Equal(f.key.raw, f2.key.raw) {
+			t.Errorf("%s: data key mismatch", sshPub.Type())
+		}
+	}
+}
+
+func TestSetupPassphraseKey(t *testing.T) {
+	f := NewFortifierWithPassphrase(false, nil, []byte("correct horse battery staple"))
+	f.SetArgon2Cost(1, 1024, 1)
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("SetupKey for encryption failed: %v", err)
+	}
+	if f.meta.Key != CipherKeyKindPassphrase || f.meta.Passphrase.Salt == "" {
+		t.Fatalf("unexpected metadata: %+v", f.meta.Passphrase)
+	}
+	f2 := NewFortifierWithPassphrase(false, f.meta, []byte("correct horse battery staple"))
+	if err := f2.SetupKey(); err != nil {
+		t.Fatalf("SetupKey for decryption failed: %v", err)
+	}
+	if !bytes.Equal(f.key.raw, f2.key.raw) {
+		t.Error("data key mismatch")
+	}
+	f3 := NewFortifierWithPassphrase(false, f.meta, []byte("wrong"))
+	if err := f3.SetupKey(); err == nil {
+		t.Error("expected error for a wrong passphrase")
+	}
+	// Costs tampered in the metadata are refused before the key is derived
+	for _, m := range []MetadataPassphrase{{Time: MaxArgon2Time + 1, Memory: 1024, Threads: 1},
+		{Time: 1, Memory: MaxArgon2Memory + 1, Threads: 1}} {
+		m.Salt, m.Digest = f.meta.Passphrase.Salt, f.meta.Passphrase.Digest
+		f4 := NewFortifierWithPassphrase(false, &Metadata{Passphrase: &m}, []byte("correct horse battery staple"))
+		if err := f4.SetupKey(); err == nil || !strings.Contains(err.Error(), "exceeds the limit") {
+			t.Errorf("expected error for costs %d/%d, got %v", m.Time, m.Memory, err)
+		}
+	}
+	// Costs at the limit pass the check, and fail on the salt before any memory is spent
+	m := MetadataPassphrase{Time: MaxArgon2Time, Memory: MaxArgon2Memory, Threads: 1, Salt: "!", Digest: f.meta.Passphrase.Digest}
+	f5 := NewFortifierWithPassphrase(false, &Metadata{Passphrase: &m}, []byte("correct horse battery staple"))
+	if err := f5.SetupKey(); err == nil || strings.Contains(err.Error(), "exceeds the limit") {
+		t.Errorf("expected a salt error for costs at the limit, got %v", err)
+	}
+}
+
 func TestCipherKeyData_NewSha256_Consistency(t *testing.T) {
 	key1 := &CipherKeyData{raw: []byte("test-key-32-bytes-long-for-testing!!")}
 	key2 := &CipherKeyData{raw: []byte("test-key-32-bytes-long-for-testing!!")}
@@ -168,7 +385,8 @@ func TestCipherModeName_String(t *testing.T) {
 func TestCipherMode_NewEncrypter(t *testing.T) {
 	f := NewFortifierWithSss(false, true, nil)
 
-	modes := []CipherModeName{CipherModeAes256CTR, CipherModeAes256CFB, CipherModeAes256OFB}
+	modes := []CipherModeName{CipherModeAes256CTR, CipherModeAes256CFB, CipherModeAes256OFB,
+		CipherModeAes256GCM, CipherModeXChaCha20Poly1305}
 	for _, mode := range modes {
 		enc := NewEncrypter(mode, f)
 		if enc == nil {
@@ -186,7 +404,8 @@ func TestCipherMode_NewEncrypter(t *testing.T) {
 func TestCipherMode_NewDecrypter(t *testing.T) {
 	f := NewFortifierWithSss(false, true, nil)
 
-	modes := []CipherModeName{CipherModeAes256CTR, CipherModeAes256CFB, CipherModeAes256OFB}
+	modes := []CipherModeName{CipherModeAes256CTR, CipherModeAes256CFB, CipherModeAes256OFB,
+		CipherModeAes256GCM, CipherModeXChaCha20Poly1305}
 	for _, mode := range modes {
 		dec := NewDecrypter(mode, f)
 		if dec == nil {
@@ -1,6 +1,7 @@
 package fortifier
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/binary"
 	"encoding/json"
@@ -8,6 +9,7 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"os"
 	"reflect"
 )
 
@@ -16,6 +18,14 @@ const FileMagicNumber = uint32(0x40F1ED00)
 var layoutDataStart = "🔒fortified🔒"
 var layoutByteOrder = binary.BigEndian
 
+// layoutTrailerSize is the size of data length, head checksum and checksum written after the data
+const layoutTrailerSize = 8 + 32 + 32
+
+const (
+	layoutVersionHead    = '1'
+	layoutVersionTrailer = '2'
+)
+
 type FileLayout struct {
 	magic          uint32
 	checksum       []byte
@@ -28,6 +38,7 @@ type FileLayout struct {
 	//
 	version  rune
 	metadata *Metadata
+	trailer  bool
 }
 
 func (f *FileLayout) DataLength() uint64 {
@@ -38,10 +49,31 @@ func (f *FileLayout) Version() rune {
 	return f.version
 }
 
+func (f *FileLayout) Magic() uint32 {
+	return f.magic
+}
+
+func (f *FileLayout) Checksum() []byte {
+	return f.checksum
+}
+
+func (f *FileLayout) HeadChecksum() []byte {
+	return f.headChecksum
+}
+
+func (f *FileLayout) MetadataLength() uint32 {
+	return f.metadataLength
+}
+
 func (f *FileLayout) Metadata() *Metadata {
 	return f.metadata
 }
 
+// HasTrailer reports whether the checksums and data length are written after the data
+func (f *FileLayout) HasTrailer() bool {
+	return f.trailer
+}
+
 func (f *FileLayout) String() string {
 	return fmt.Sprintf("\nMagic: %X\nVersion: %c\nChecksum: %X\nData Length: %d\n"+
 		"Head Checksum: %X\nMetadata Length: %d\nMetadata Raw: %s\nData Start Mark: %s\nNonce: %X\n",
@@ -58,16 +90,19 @@ func (f *FileLayout) ReadHeadIn(in io.Reader) (err error) {
 	if FileMagicNumber != (f.magic & 0x7FFFFF00) {
 		return errors.New("not a fortified input file")
 	}
-	f.checksum = make([]byte, 32)
-	if err = binary.Read(in, endian, f.checksum); err != nil {
-		return
-	}
-	if err = binary.Read(in, endian, &f.dataLength); err != nil {
-		return
+	f.version = rune(0xFF & f.magic)
+	switch f.version {
+	case layoutVersionHead:
+		f.trailer = false
+	case layoutVersionTrailer:
+		f.trailer = true
+	default:
+		return fmt.Errorf("unsupported fortified file version: %c", f.version)
 	}
-	f.headChecksum = make([]byte, 32)
-	if err = binary.Read(in, endian, f.headChecksum); err != nil {
-		return
+	if !f.trailer {
+		if err = f.readChecksums(in); err != nil {
+			return
+		}
 	}
 	if err = binary.Read(in, endian, &f.metadataLength); err != nil {
 		return
@@ -85,16 +120,77 @@ func (f *FileLayout) ReadHeadIn(in io.Reader) (err error) {
 		return
 	}
 	//
-	f.version = rune(0xFF & f.magic)
 	f.metadata = &Metadata{}
 	if err = json.Unmarshal(f.metadataRaw, f.metadata); err != nil {
 		return
 	}
+	for _, r := range f.metadata.Recipients {
+		if r == nil {
+			return errors.New("fortified file metadata has a null recipient")
+		}
+	}
 	return
 }
 
+// ReadTailIn reads data length and checksums from the trailer of a fortified file
+func (f *FileLayout) ReadTailIn(in io.Reader) (err error) {
+	if !f.trailer {
+		return errors.New("fortified file has no trailer")
+	}
+	return f.readChecksums(in)
+}
+
+// ReadTailAfterData reads the trailer of a fortified file once ReadHeadIn is done with in,
+// seeking to it if in is a regular file, or else reading through the data
+func (f *FileLayout) ReadTailAfterData(in io.Reader) (err error) {
+	if !f.trailer {
+		return
+	}
+	if file, ok := in.(*os.File); ok {
+		if special, e := notRegularFile(file); e == nil && !special {
+			if _, err = file.Seek(-layoutTrailerSize, io.SeekEnd); err != nil {
+				return
+			}
+			return f.ReadTailIn(file)
+		}
+	}
+	tr := newTrailerReader(in, layoutTrailerSize)
+	if _, err = io.Copy(io.Discard, tr); err != nil {
+		return
+	}
+	return f.ReadTailIn(tr.Trailer())
+}
+
+func (f *FileLayout) readChecksums(in io.Reader) (err error) {
+	endian := layoutByteOrder
+	if f.trailer {
+		if err = binary.Read(in, endian, &f.dataLength); err != nil {
+			return
+		}
+		f.headChecksum = make([]byte, 32)
+		if err = binary.Read(in, endian, f.headChecksum); err != nil {
+			return
+		}
+		f.checksum = make([]byte, 32)
+		return binary.Read(in, endian, f.checksum)
+	}
+	f.checksum = make([]byte, 32)
+	if err = binary.Read(in, endian, f.checksum); err != nil {
+		return
+	}
+	if err = binary.Read(in, endian, &f.dataLength); err != nil {
+		return
+	}
+	f.headChecksum = make([]byte, 32)
+	return binary.Read(in, endian, f.headChecksum)
+}
+
 func (f *FileLayout) WriteHeadOut(out io.Writer) (err error) {
-	f.magic = FileMagicNumber | '1'
+	if f.trailer {
+		f.magic = FileMagicNumber | layoutVersionTrailer
+	} else {
+		f.magic = FileMagicNumber | layoutVersionHead
+	}
 	f.version = rune(0xFF & f.magic)
 	if f.metadataRaw, err = json.Marshal(f.metadata); err != nil {
 		return
@@ -108,11 +204,27 @@ func (f *FileLayout) WriteHeadOut(out io.Writer) (err error) {
 	if _, err = rand.Read(f.nonce); err != nil {
 		return
 	}
-	items := []any{f.magic, f.checksum, f.dataLength, f.headChecksum,
-		f.metadataLength, f.metadataRaw, f.dataStartMark, f.nonce}
 	if out == nil {
 		return
 	}
+	return writeLayoutItems(out, f.headItems())
+}
+
+// headItems returns the fields of the head in the order they are written
+func (f *FileLayout) headItems() []any {
+	if f.trailer {
+		return []any{f.magic, f.metadataLength, f.metadataRaw, f.dataStartMark, f.nonce}
+	}
+	return []any{f.magic, f.checksum, f.dataLength, f.headChecksum,
+		f.metadataLength, f.metadataRaw, f.dataStartMark, f.nonce}
+}
+
+// tailItems returns the fields of the trailer in the order they are written
+func (f *FileLayout) tailItems() []any {
+	return []any{f.dataLength, f.headChecksum, f.checksum}
+}
+
+func writeLayoutItems(out io.Writer, items []any) (err error) {
 	endian := layoutByteOrder
 	for _, item := range items {
 		if err = binary.Write(out, endian, item); err != nil {
@@ -147,6 +259,15 @@ func (f *FileLayout) WriteHeadPlaceHolders(
 	return
 }
 
+// WriteTailOut writes data length and checksums after the data, for outputs which cannot seek
+func (f *FileLayout) WriteTailOut(out io.Writer, key *CipherKeyData, check hash.Hash, dataLen int64) (err error) {
+	f.dataLength = uint64(dataLen)
+	if err = f.makeChecksum(key, check); err != nil {
+		return
+	}
+	return writeLayoutItems(out, f.tailItems())
+}
+
 func (f *FileLayout) makeChecksum(key *CipherKeyData, check hash.Hash) (err error) {
 	f.headChecksum = f.makeChecksumHead(key)
 	check.Write(f.headChecksum)
@@ -171,3 +292,46 @@ func (f *FileLayout) makeChecksumHead(key *CipherKeyData) []byte {
 	check.Write(f.nonce)
 	return check.Sum(nil)
 }
+
+// trailerReader holds back the trailer of a fortified stream, so that the data ends with io.EOF
+type trailerReader struct {
+	r     io.Reader
+	size  int
+	buf   []byte
+	start int
+	end   int
+	err   error
+}
+
+func newTrailerReader(r io.Reader, size int) *trailerReader {
+	return &trailerReader{r: r, size: size, buf: make([]byte, size+defaultReaderBufferSize)}
+}
+
+func (t *trailerReader) Read(p []byte) (n int, err error) {
+	for t.end-t.start <= t.size {
+		if t.err != nil {
+			if !errors.Is(t.err, io.EOF) {
+				return 0, t.err
+			}
+			if t.end-t.start < t.size {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, io.EOF
+		}
+		if t.start > 0 {
+			t.end = copy(t.buf, t.buf[t.start:t.end])
+			t.start = 0
+		}
+		var m int
+		m, t.err = t.r.Read(t.buf[t.end:])
+		t.end += m
+	}
+	n = copy(p, t.buf[t.start:t.end-t.size])
+	t.start += n
+	return
+}
+
+// Trailer returns the bytes held back, which is the trailer once Read returned io.EOF
+func (t *trailerReader) Trailer() io.Reader {
+	return bytes.NewReader(t.buf[t.start:t.end])
+}
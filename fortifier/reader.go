@@ -0,0 +1,171 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Reader decrypts any byte range of a fortified file in cipher mode aes256-ctr,
+// without reading the data before that range.
+type Reader struct {
+	*Fortifier
+	file     io.ReaderAt
+	layout   *FileLayout
+	iv       []byte
+	start    int64
+	size     int64
+	offset   int64
+	verified bool
+}
+
+// OpenReader reads the head of a fortified file and sets up the key of f for random access.
+// The checksum of the whole file is verified up front if verify is true,
+// otherwise it is left to the caller to call Verify whenever it suits.
+func OpenReader(file *os.File, f *Fortifier, verify bool) (r *Reader, err error) {
+	var stat os.FileInfo
+	if stat, err = file.Stat(); err != nil {
+		return
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", file.Name())
+	}
+	section := io.NewSectionReader(file, 0, stat.Size())
+	layout := &FileLayout{}
+	if err = layout.ReadHeadIn(section); err != nil {
+		return
+	}
+	meta := layout.Metadata()
+	if meta.Mode != CipherModeAes256CTR {
+		return nil, fmt.Errorf("random access requires cipher mode %s, not %s", CipherModeAes256CTR, meta.Mode)
+	}
+	if meta.Compression != CompressionNone {
+		return nil, fmt.Errorf("random access requires uncompressed data, not %s", meta.Compression)
+	}
+	if err = f.SetupKey(); err != nil {
+		return
+	}
+	r = &Reader{Fortifier: f, file: file, layout: layout, iv: make([]byte, f.block.BlockSize())}
+	if _, err = io.ReadFull(section, r.iv); err != nil {
+		return nil, err
+	}
+	var end int64
+	if r.start, err = section.Seek(0, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	if end = stat.Size(); layout.trailer {
+		end -= layoutTrailerSize
+		if err = layout.ReadTailIn(io.NewSectionReader(file, end, layoutTrailerSize)); err != nil {
+			return nil, err
+		}
+	}
+	r.size = int64(layout.dataLength)
+	if end-r.start != r.size {
+		return nil, fmt.Errorf("%w: expect data length is %d, not %d", ErrDataLength, layout.dataLength, end-r.start)
+	}
+	dec := &Aes256StreamDecrypter{f}
+	if err = dec.verifyHead(layout); err != nil {
+		return nil, err
+	}
+	if f.meta.Sss != nil && meta.Sss != nil && meta.Sss.Digest != f.meta.Sss.Digest {
+		return nil, ErrKeyDigest
+	}
+	if verify {
+		if err = r.Verify(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Size returns the length of the decrypted data
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Layout returns the layout of the fortified file
+func (r *Reader) Layout() *FileLayout {
+	return r.layout
+}
+
+// Verify reads through the whole data once to check the checksum of the file
+func (r *Reader) Verify() (err error) {
+	if r.verified {
+		return
+	}
+	check := r.key.NewSha256()
+	check.Write(r.iv)
+	if _, err = io.Copy(check, io.NewSectionReader(r, 0, r.size)); err != nil {
+		return
+	}
+	check.Write(r.layout.headChecksum)
+	if !bytes.Equal(r.layout.checksum, check.Sum(nil)) {
+		return ErrInvalidChecksum
+	}
+	r.verified = true
+	return
+}
+
+func (r *Reader) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n = len(p)
+	if rest := r.size - off; int64(n) > rest {
+		n = int(rest)
+		err = io.EOF
+	}
+	if _, e := r.file.ReadAt(p[:n], r.start+off); e != nil && !errors.Is(e, io.EOF) {
+		return 0, e
+	}
+	r.streamAt(off).XORKeyStream(p[:n], p[:n])
+	return
+}
+
+func (r *Reader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	return
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// streamAt returns the CTR key stream positioned at offset off of the data
+func (r *Reader) streamAt(off int64) cipher.Stream {
+	size := r.block.BlockSize()
+	counter := make([]byte, size)
+	copy(counter, r.iv)
+	carry := uint64(off / int64(size))
+	for i := size - 1; i >= 0 && carry > 0; i-- {
+		sum := uint64(counter[i]) + carry&0xFF
+		counter[i] = byte(sum)
+		carry = carry>>8 + sum>>8
+	}
+	stream := cipher.NewCTR(r.block, counter)
+	if skip := int(off % int64(size)); skip > 0 {
+		discard := make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	return stream
+}
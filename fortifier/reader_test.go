@@ -0,0 +1,91 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenReader_RandomAccess(t *testing.T) {
+	plaintext := make([]byte, 100000)
+	for i := range plaintext {
+		plaintext[i] = byte(i * 7)
+	}
+	f, data := encryptToBytes(t, CipherModeAes256CTR, plaintext)
+	path := filepath.Join(t.TempDir(), "fortified.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write fortified file: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open fortified file: %v", err)
+	}
+	defer file.Close()
+
+	r, err := OpenReader(file, &Fortifier{meta: &Metadata{}, key: f.key, block: f.block}, true)
+	if err != nil {
+		t.Fatalf("OpenReader failed: %v", err)
+	}
+	if r.Size() != int64(len(plaintext)) {
+		t.Fatalf("expected size %d, got %d", len(plaintext), r.Size())
+	}
+	for _, span := range [][2]int{{0, 1}, {15, 17}, {16, 32}, {4095, 9000}, {99990, 100000}} {
+		buf := make([]byte, span[1]-span[0])
+		if _, err = r.ReadAt(buf, int64(span[0])); err != nil && err != io.EOF {
+			t.Fatalf("ReadAt %v failed: %v", span, err)
+		}
+		if !bytes.Equal(buf, plaintext[span[0]:span[1]]) {
+			t.Errorf("ReadAt %v: content mismatch", span)
+		}
+	}
+	if _, err = r.Seek(-10, io.SeekEnd); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	tail, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(tail, plaintext[len(plaintext)-10:]) {
+		t.Error("tail content mismatch")
+	}
+}
+
+func TestOpenReader_RejectsOtherModes(t *testing.T) {
+	f, data := encryptToBytes(t, CipherModeAes256GCM, []byte("not seekable"))
+	path := filepath.Join(t.TempDir(), "fortified.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write fortified file: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open fortified file: %v", err)
+	}
+	defer file.Close()
+	if _, err = OpenReader(file, f, false); err == nil {
+		t.Error("expected error for cipher mode other than aes256-ctr")
+	}
+}
+
+func TestReader_StreamAtCounterCarry(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("failed to create cipher block: %v", err)
+	}
+	iv := bytes.Repeat([]byte{0xFF}, aes.BlockSize)
+	iv[0] = 0x00
+	expect := make([]byte, 4096)
+	cipher.NewCTR(block, iv).XORKeyStream(expect, expect)
+	r := &Reader{Fortifier: &Fortifier{block: block}, iv: iv}
+	for _, off := range []int64{1, 16, 17, 255 * 16, 4000} {
+		actual := make([]byte, len(expect)-int(off))
+		r.streamAt(off).XORKeyStream(actual, actual)
+		if !bytes.Equal(actual, expect[off:]) {
+			t.Errorf("key stream mismatch at offset %d", off)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package fortifier
+
+import (
+	"crypto/aes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Rewrap wraps the data key of the fortified file at path with the keys of to,
+// so that they unlock the file in place of the keys of f. The data is read once to
+// verify it and to compute the new checksum, but it is never encrypted again.
+//
+// The file is copied with the new head to a temporary file next to it, which replaces the
+// original file once it is complete, so that the file at path is either the original file or
+// the rewrapped one, even if rewrapping is interrupted or another process reads it meanwhile.
+func Rewrap(path string, f, to *Fortifier) (err error) {
+	var file *os.File
+	if file, err = os.Open(path); err != nil {
+		return
+	}
+	defer func() {
+		if file != nil {
+			_ = file.Close()
+		}
+	}()
+	var stat os.FileInfo
+	if stat, err = file.Stat(); err != nil {
+		return
+	}
+	if !stat.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+	started := time.Now()
+	size := stat.Size()
+	section := io.NewSectionReader(file, 0, size)
+	layout := &FileLayout{}
+	if err = layout.ReadHeadIn(section); err != nil {
+		return
+	}
+	var headSize int64
+	if headSize, err = section.Seek(0, io.SeekCurrent); err != nil {
+		return
+	}
+	meta := layout.Metadata()
+	var mode CipherMode
+	if mode, err = decryptModeOf(meta.Mode); err != nil {
+		return
+	}
+	if err = f.SetupKey(); err != nil {
+		return
+	}
+	recheck := f.key.NewSha256()
+	dec := &Aes256StreamDecrypter{f}
+	if err = dec.decrypt(io.NewSectionReader(file, headSize, size-headSize), nil, layout, mode, recheck); err != nil {
+		return
+	}
+	if err = to.wrapKey(f.key.raw, meta); err != nil {
+		return
+	}
+	var next *FileLayout
+	if next, err = layout.rewrapped(to.meta); err != nil {
+		return
+	}
+	next.headChecksum = next.makeChecksumHead(to.key)
+	recheck.Write(next.headChecksum)
+	next.checksum = recheck.Sum(nil)
+	if to.verbose {
+		fmt.Printf("%s *-->* %s [%s -> %s]\n", path, path, meta.Key, to.meta.Key)
+	}
+	end := size
+	if layout.trailer {
+		end -= layoutTrailerSize
+	}
+	var tmp string
+	data := io.NewSectionReader(file, headSize, end-headSize)
+	if tmp, err = writeTempFile(path, stat.Mode().Perm(), next, data); err != nil {
+		return
+	}
+	_ = file.Close()
+	file = nil
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return
+	}
+	if to.verbose {
+		fmt.Printf("%s *-->* %s %d bytes (%v) OK\n", path, path, next.dataLength, time.Since(started))
+	}
+	return
+}
+
+// wrapKey wraps raw, the data key of an existing fortified file with metadata meta, with the keys of f
+func (f *Fortifier) wrapKey(raw []byte, meta *Metadata) (err error) {
+	f.key.raw = raw
+	switch f.key.kind {
+	case CipherKeyKindRSA:
+		err = f.setupRsaPublicKey()
+	case CipherKeyKindECDH:
+		err = f.setupEcdhPublicKey()
+	case CipherKeyKindPassphrase:
+		err = fmt.Errorf("cannot rewrap to cipher key kind %s, which derives the data key", f.key.kind)
+	default:
+		if len(f.key.parts) > 0 {
+			err = errors.New("cannot rewrap to existing secret shares of another key")
+		} else {
+			err = f.setupSssKey()
+		}
+	}
+	if err != nil {
+		f.key.raw = nil
+		return
+	}
+	f.meta.Mode = meta.Mode
+	f.meta.Content = meta.Content
+	f.meta.Compression = meta.Compression
+	f.block, err = aes.NewCipher(raw)
+	return
+}
+
+// rewrapped returns a copy of the layout with metadata meta
+func (f *FileLayout) rewrapped(meta *Metadata) (next *FileLayout, err error) {
+	next = &FileLayout{
+		magic:         f.magic,
+		dataLength:    f.dataLength,
+		dataStartMark: f.dataStartMark,
+		nonce:         f.nonce,
+		version:       f.version,
+		metadata:      meta,
+		trailer:       f.trailer,
+	}
+	if next.metadataRaw, err = json.Marshal(meta); err != nil {
+		return
+	}
+	next.metadataLength = uint32(len(next.metadataRaw))
+	return
+}
+
+// writeTempFile writes the layout around data to a temporary file next to the file at path
+func writeTempFile(path string, perm os.FileMode, layout *FileLayout, data io.Reader) (name string, err error) {
+	var tmp *os.File
+	if tmp, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".rewrap-*"); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if err = writeLayoutItems(tmp, layout.headItems()); err != nil {
+		return
+	}
+	if _, err = io.Copy(tmp, data); err != nil {
+		return
+	}
+	if layout.trailer {
+		if err = writeLayoutItems(tmp, layout.tailItems()); err != nil {
+			return
+		}
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return
+	}
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+	return tmp.Name(), tmp.Close()
+}
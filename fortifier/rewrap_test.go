@@ -0,0 +1,137 @@
+package fortifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func generateRsaKeyPem(t *testing.T) (pub, pri []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+	}
+	pub = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	pri = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return
+}
+
+func decryptFileWithRsa(path string, pri []byte) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(data)
+	layout := &FileLayout{}
+	if err = layout.ReadHeadIn(r); err != nil {
+		return nil, err
+	}
+	f := NewFortifierWithRsa(false, layout.Metadata(), pri)
+	var buf bytes.Buffer
+	err = NewDecrypter(layout.Metadata().Mode, f).Decrypt(r, &buf, layout)
+	return buf.Bytes(), err
+}
+
+func TestRewrap_SssToRsa(t *testing.T) {
+	for _, mode := range []CipherModeName{CipherModeAes256CFB, CipherModeXChaCha20Poly1305} {
+		plaintext := bytes.Repeat([]byte("rewrap me "), 20000)
+		f, data := encryptToBytes(t, mode, plaintext)
+		path := filepath.Join(t.TempDir(), "fortified.bin")
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		pub1, pri1 := generateRsaKeyPem(t)
+		pub2, pri2 := generateRsaKeyPem(t)
+
+		if err := Rewrap(path, f, NewFortifierWithRsa(false, nil, pub1, pub2)); err != nil {
+			t.Fatalf("%s: Rewrap to 2 recipients failed: %v", mode, err)
+		}
+		rewrapped, _ := os.ReadFile(path)
+		for i, pri := range [][]byte{pri1, pri2} {
+			decrypted, err := decryptFileWithRsa(path, pri)
+			if err != nil {
+				t.Fatalf("%s: recipient %d: Decrypt failed: %v", mode, i, err)
+			}
+			if !bytes.Equal(plaintext, decrypted) {
+				t.Fatalf("%s: recipient %d: decrypted content mismatch", mode, i)
+			}
+		}
+		if !bytes.HasSuffix(rewrapped, data[len(data)-len(plaintext):]) {
+			t.Errorf("%s: expected the encrypted data to stay as it is", mode)
+		}
+
+		// Rewrapping again for fewer recipients
+		layout := &FileLayout{}
+		if err := layout.ReadHeadIn(bytes.NewReader(rewrapped)); err != nil {
+			t.Fatalf("ReadHeadIn failed: %v", err)
+		}
+		old := NewFortifierWithRsa(false, layout.Metadata(), pri1)
+		if err := Rewrap(path, old, NewFortifierWithRsa(false, nil, pub2)); err != nil {
+			t.Fatalf("%s: Rewrap to 1 recipient failed: %v", mode, err)
+		}
+		if _, err := decryptFileWithRsa(path, pri1); err == nil {
+			t.Errorf("%s: expected the removed recipient to fail", mode)
+		}
+		decrypted, err := decryptFileWithRsa(path, pri2)
+		if err != nil {
+			t.Fatalf("%s: Decrypt after the second rewrap failed: %v", mode, err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("%s: decrypted content mismatch after the second rewrap", mode)
+		}
+	}
+}
+
+func TestRewrap_Trailer(t *testing.T) {
+	plaintext := []byte("fortified through a pipe")
+	f := NewFortifierWithSss(false, true, nil)
+	if err := f.SetupKey(); err != nil {
+		t.Fatalf("SetupKey failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncrypter(CipherModeAes256CTR, f).Encrypt(bytes.NewReader(plaintext), &buf); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "fortified.bin")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	pub, pri := generateRsaKeyPem(t)
+	if err := Rewrap(path, f, NewFortifierWithRsa(false, nil, pub)); err != nil {
+		t.Fatalf("Rewrap failed: %v", err)
+	}
+	decrypted, err := decryptFileWithRsa(path, pri)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !bytes.Equal(plaintext, decrypted) {
+		t.Error("decrypted content mismatch")
+	}
+}
+
+func TestRewrap_TamperedData(t *testing.T) {
+	f, data := encryptToBytes(t, CipherModeAes256CTR, []byte("do not rewrap me when tampered"))
+	data[len(data)-1] ^= 0x01
+	path := filepath.Join(t.TempDir(), "fortified.bin")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	pub, _ := generateRsaKeyPem(t)
+	if err := Rewrap(path, f, NewFortifierWithRsa(false, nil, pub)); err == nil {
+		t.Fatal("expected Rewrap to fail")
+	}
+	after, _ := os.ReadFile(path)
+	if !bytes.Equal(data, after) {
+		t.Error("expected the file to stay unchanged")
+	}
+}
@@ -0,0 +1,46 @@
+package fortifier
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+type XChaCha20EncrypterPoly1305 struct {
+	Aes256StreamEncrypter
+}
+
+func NewXChaCha20EncrypterPoly1305(f *Fortifier) *XChaCha20EncrypterPoly1305 {
+	return &XChaCha20EncrypterPoly1305{Aes256StreamEncrypter{f}}
+}
+
+func (f *XChaCha20EncrypterPoly1305) EncryptFile(in, out *os.File) error {
+	f.meta.Mode = CipherModeXChaCha20Poly1305
+	return f.Aes256StreamEncrypter.EncryptFile(in, out,
+		CipherMode{Name: CipherModeXChaCha20Poly1305, AeadMaker: chacha20poly1305.NewX})
+}
+
+func (f *XChaCha20EncrypterPoly1305) Encrypt(r io.Reader, w io.Writer) error {
+	f.meta.Mode = CipherModeXChaCha20Poly1305
+	return f.Aes256StreamEncrypter.encryptStream(r, w, &FileLayout{metadata: f.meta},
+		CipherMode{Name: CipherModeXChaCha20Poly1305, AeadMaker: chacha20poly1305.NewX})
+}
+
+type XChaCha20DecrypterPoly1305 struct {
+	Aes256StreamDecrypter
+}
+
+func NewXChaCha20DecrypterPoly1305(f *Fortifier) *XChaCha20DecrypterPoly1305 {
+	return &XChaCha20DecrypterPoly1305{Aes256StreamDecrypter{f}}
+}
+
+func (f *XChaCha20DecrypterPoly1305) Decrypt(r io.Reader, w io.Writer, layout *FileLayout) error {
+	return f.Aes256StreamDecrypter.Decrypt(r, w, layout,
+		CipherMode{Name: CipherModeXChaCha20Poly1305, AeadMaker: chacha20poly1305.NewX})
+}
+
+func (f *XChaCha20DecrypterPoly1305) DecryptFile(in, out *os.File, layout *FileLayout) error {
+	return f.Aes256StreamDecrypter.DecryptFile(in, out, layout,
+		CipherMode{Name: CipherModeXChaCha20Poly1305, AeadMaker: chacha20poly1305.NewX})
+}
@@ -0,0 +1,259 @@
+// Package envfile parses environment variables from dotenv, JSON and flat YAML files.
+package envfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatAuto   Format = "auto"
+	FormatDotenv Format = "dotenv"
+	FormatJson   Format = "json"
+	FormatYaml   Format = "yaml"
+)
+
+// Variable is an environment variable in the order of the file
+type Variable struct {
+	Name  string
+	Value string
+}
+
+var (
+	namePattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	exportPattern = regexp.MustCompile(`^export\s+`)
+	dotenvPattern = regexp.MustCompile(`^(export\s+)?[A-Za-z_][A-Za-z0-9_]*\s*=`)
+	yamlPattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\s*:(\s|$)`)
+)
+
+// Parse parses the variables in data of format, or of the format detected from the first variable if it is FormatAuto.
+// A variable defined more than once takes the last value. Values are taken as they are, without any expansion.
+func Parse(data []byte, format Format) ([]Variable, error) {
+	if format == FormatAuto {
+		format = Detect(data)
+	}
+	var vars []Variable
+	var err error
+	switch format {
+	case FormatDotenv:
+		vars, err = parseDotenv(data)
+	case FormatJson:
+		vars, err = parseJson(data)
+	case FormatYaml:
+		vars, err = parseYaml(data)
+	default:
+		return nil, fmt.Errorf("unknown format of environment file: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", format, err)
+	}
+	return vars, nil
+}
+
+// Detect returns FormatJson for an object, FormatYaml if the first variable is defined with a colon,
+// otherwise FormatDotenv
+func Detect(data []byte) Format {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatJson
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line == "---" || yamlPattern.MatchString(line):
+			return FormatYaml
+		default:
+			return FormatDotenv
+		}
+	}
+	return FormatDotenv
+}
+
+func checkName(name string, line int) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("line %d: invalid variable name %q", line, name)
+	}
+	return nil
+}
+
+func parseDotenv(data []byte) (vars []Variable, err error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		no := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !dotenvPattern.MatchString(line) {
+			return nil, fmt.Errorf("line %d: expect NAME=value", no)
+		}
+		name, value, _ := strings.Cut(exportPattern.ReplaceAllString(line, ""), "=")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			// A quoted value may span lines up to its closing quote
+			quote := value[0]
+			for closingQuote(value, quote) < 0 && i+1 < len(lines) {
+				i++
+				value += "\n" + lines[i]
+			}
+			end := closingQuote(value, quote)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value of %s", no, name)
+			}
+			if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("line %d: unexpected text after quoted value of %s", no, name)
+			}
+			var ok bool
+			if value, ok = unquote(value[:end+1]); !ok {
+				return nil, fmt.Errorf("line %d: invalid quoted value of %s", no, name)
+			}
+		} else if j := strings.Index(value, " #"); j >= 0 {
+			value = strings.TrimSpace(value[:j])
+		}
+		vars = append(vars, Variable{Name: name, Value: value})
+	}
+	return
+}
+
+// closingQuote returns the index of the quote closing the value starting with quote, or -1 if there is none
+func closingQuote(value string, quote byte) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote returns the value of a single-quoted string as it is, and of a double-quoted string with escapes.
+// It reports whether s is valid rather than an error, which must not tell the secret in s.
+func unquote(s string) (string, bool) {
+	if s[0] == '\'' {
+		return s[1 : len(s)-1], true
+	}
+	v, err := strconv.Unquote(strings.ReplaceAll(s, "\n", `\n`))
+	return v, err == nil
+}
+
+func parseJson(data []byte) (vars []Variable, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tok json.Token
+	if tok, err = dec.Token(); err != nil {
+		return
+	}
+	if tok != json.Delim('{') {
+		return nil, errors.New("expect an object")
+	}
+	for dec.More() {
+		if tok, err = dec.Token(); err != nil {
+			return
+		}
+		name := tok.(string)
+		if !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+		var raw any
+		if err = dec.Decode(&raw); err != nil {
+			return
+		}
+		var value string
+		switch v := raw.(type) {
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		case bool:
+			value = strconv.FormatBool(v)
+		case nil:
+		default:
+			return nil, fmt.Errorf("value of %s is not a string, number, boolean or null", name)
+		}
+		vars = append(vars, Variable{Name: name, Value: value})
+	}
+	if _, err = dec.Token(); err != nil {
+		return
+	}
+	if _, err = dec.Token(); err == nil {
+		return nil, errors.New("unexpected data after the object")
+	}
+	return vars, nil
+}
+
+// parseYaml parses a flat mapping of names to scalar values, which covers the files of secrets,
+// but no nested mappings, sequences, anchors or block scalars
+func parseYaml(data []byte) (vars []Variable, err error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i, text := range lines {
+		no := i + 1
+		line := strings.TrimRight(text, " \t")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || (i == 0 && trimmed == "---") {
+			continue
+		}
+		if line != strings.TrimLeft(line, " \t") {
+			return nil, fmt.Errorf("line %d: nested values are not supported", no)
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expect NAME: value", no)
+		}
+		name = strings.TrimSpace(name)
+		if err = checkName(name, no); err != nil {
+			return
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "" || value == "~" || value == "null":
+			value = ""
+		case value[0] == '"' || value[0] == '\'':
+			end := closingQuote(value, value[0])
+			if value[0] == '\'' {
+				// Two single quotes stand for one in a single-quoted value
+				for end >= 0 && end+1 < len(value) && value[end+1] == '\'' {
+					if next := closingQuote(value[end+1:], '\''); next < 0 {
+						end = -1
+					} else {
+						end += 1 + next
+					}
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value of %s", no, name)
+			}
+			if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("line %d: unexpected text after quoted value of %s", no, name)
+			}
+			if value[0] == '\'' {
+				value = strings.ReplaceAll(value[1:end], "''", "'")
+			} else if value, ok = unquote(value[:end+1]); !ok {
+				return nil, fmt.Errorf("line %d: invalid quoted value of %s", no, name)
+			}
+		case strings.ContainsAny(value[:1], "[{|>&*!"):
+			return nil, fmt.Errorf("line %d: value of %s is not a scalar", no, name)
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+		vars = append(vars, Variable{Name: name, Value: value})
+	}
+	return
+}
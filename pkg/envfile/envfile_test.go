@@ -0,0 +1,103 @@
+package envfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse_Formats(t *testing.T) {
+	expect := []Variable{
+		{"DB_USER", "admin"},
+		{"DB_PASS", "p@ss word#1"},
+		{"PORT", "5432"},
+		{"EMPTY", ""},
+		{"QUOTE", "it's"},
+	}
+	cases := map[Format]string{
+		FormatDotenv: `# secrets
+DB_USER=admin # the user
+export DB_PASS="p@ss word#1"
+PORT=5432
+EMPTY=
+QUOTE="it's"
+`,
+		FormatJson: `{"DB_USER": "admin", "DB_PASS": "p@ss word#1", "PORT": 5432, "EMPTY": null, "QUOTE": "it's"}`,
+		FormatYaml: `---
+# secrets
+DB_USER: admin # the user
+DB_PASS: "p@ss word#1"
+PORT: 5432
+EMPTY:
+QUOTE: 'it''s'
+`,
+	}
+	for format, data := range cases {
+		if detected := Detect([]byte(data)); detected != format {
+			t.Errorf("%s: detected as %s", format, detected)
+		}
+		vars, err := Parse([]byte(data), FormatAuto)
+		if err != nil {
+			t.Fatalf("%s: Parse failed: %v", format, err)
+		}
+		if !reflect.DeepEqual(vars, expect) {
+			t.Errorf("%s: expected %v, got %v", format, expect, vars)
+		}
+	}
+}
+
+func TestParse_DotenvMultiline(t *testing.T) {
+	data := "KEY=\"-----BEGIN KEY-----\nabc\\\"def\n-----END KEY-----\"\nNEXT=1\n"
+	vars, err := Parse([]byte(data), FormatDotenv)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	expect := []Variable{{"KEY", "-----BEGIN KEY-----\nabc\"def\n-----END KEY-----"}, {"NEXT", "1"}}
+	if !reflect.DeepEqual(vars, expect) {
+		t.Errorf("expected %q, got %q", expect, vars)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	cases := map[string]Format{
+		"1KEY=value":              FormatDotenv,
+		"KEY value":               FormatDotenv,
+		"KEY=\"unterminated":      FormatDotenv,
+		"KEY='a' b":               FormatDotenv,
+		`{"KEY": {"nested": 1}}`:  FormatJson,
+		`{"BAD-NAME": "x"}`:       FormatJson,
+		`["KEY"]`:                 FormatJson,
+		"KEY:\n  nested: value":   FormatYaml,
+		"KEY: [a, b]":             FormatYaml,
+		"KEY: 'unterminated":      FormatYaml,
+		"KEY: value\nBAD NAME: x": FormatYaml,
+	}
+	for data, format := range cases {
+		if vars, err := Parse([]byte(data), format); err == nil {
+			t.Errorf("%s %q: expected error, got %v", format, data, vars)
+		}
+	}
+	if _, err := Parse(nil, "toml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestParse_ErrorsKeepSecrets(t *testing.T) {
+	// The decrypted values must not reach the errors, which are printed
+	cases := map[string]Format{
+		`KEY="s3cr"XyZ`:        FormatDotenv,
+		`KEY="s3cr\qXyZ"`:      FormatDotenv,
+		"KEY='s3cr'XyZ\nA=b":   FormatDotenv,
+		`KEY: "s3cr"XyZ`:       FormatYaml,
+		`KEY: "s3cr\qXyZ"`:     FormatYaml,
+		"KEY: 's3cr'XyZ\nA: b": FormatYaml,
+	}
+	for data, format := range cases {
+		_, err := Parse([]byte(data), format)
+		if err == nil {
+			t.Errorf("%s %q: expected error", format, data)
+		} else if strings.Contains(err.Error(), "s3cr") || strings.Contains(err.Error(), "XyZ") {
+			t.Errorf("%s %q: error tells the secret: %v", format, data, err)
+		}
+	}
+}
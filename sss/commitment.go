@@ -0,0 +1,199 @@
+package sss
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/i3ash/fortify/files"
+)
+
+// Verifiable shares carry the Pedersen commitments of pedersen.go, which every share of a block carries in full,
+// so that its holder checks it on its own, and combine tells the shares which do not match the commitments
+// most of the shares agree on.
+
+var (
+	ErrNotVerifiable     = errors.New("secret share carries no commitments")
+	ErrShareMismatch     = errors.New("secret share does not match the commitments")
+	ErrShareDisagreement = errors.New("secret share disagrees with the commitments of the other shares")
+	ErrShareNoMajority   = errors.New("secret shares disagree on their commitments with no majority")
+)
+
+// ShareError is the error of one of the shares combined
+type ShareError struct {
+	Index  int    // Index of the share among those combined
+	Source string // Name of the file of the share, if known
+	Err    error
+}
+
+func (e *ShareError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("share %d: %v", e.Index+1, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Source, e.Err)
+}
+
+func (e *ShareError) Unwrap() error {
+	return e.Err
+}
+
+// Verifiable reports whether p is a verifiable share, which carries commitments
+func (p *Part) Verifiable() bool {
+	return len(p.Commitments) > 0
+}
+
+// commitments returns the raw bytes of the commitments of p
+func (p *Part) commitments() ([][]byte, error) {
+	return decodeCommitments64(p.Commitments)
+}
+
+func decodeCommitments64(commitments []string) ([][]byte, error) {
+	cs := make([][]byte, len(commitments))
+	for j, c := range commitments {
+		var err error
+		if cs[j], err = base64.URLEncoding.DecodeString(c); err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidCommitment, err)
+		}
+	}
+	return cs, nil
+}
+
+func encodeCommitments(commitments [][]byte) []string {
+	if len(commitments) == 0 {
+		return nil
+	}
+	cs := make([]string, len(commitments))
+	for j, c := range commitments {
+		cs[j] = base64.URLEncoding.EncodeToString(c)
+	}
+	return cs
+}
+
+// Verify checks the share of p against its commitments
+func (p *Part) Verify() error {
+	if !p.Verifiable() {
+		return ErrNotVerifiable
+	}
+	if len(p.Commitments) != int(p.Threshold) {
+		return fmt.Errorf("threshold %d with %d commitments", p.Threshold, len(p.Commitments))
+	}
+	share, err := base64.URLEncoding.DecodeString(p.Payload)
+	if err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	defer clear(share)
+	cs, err := p.commitments()
+	if err != nil {
+		return err
+	}
+	return verifyPedersen(share, cs)
+}
+
+// commitmentKey tells the parts of one block which agree on the secret and the commitments to its shares
+func (p *Part) commitmentKey() string {
+	return fmt.Sprintf("%s/%d/%d/%d/%d/%d/%s", p.Digest, p.Parts, p.Threshold, p.Block, p.Blocks, p.Generation,
+		strings.Join(p.Commitments, ","))
+}
+
+// VerifyParts checks parts, the shares of one block, against the commitments which most of them agree on.
+// It returns the errors of the shares which fail, or nil if none of the parts carries commitments.
+func VerifyParts(parts []Part) []*ShareError {
+	counts := make(map[string]int, len(parts))
+	for i := range parts {
+		if parts[i].Verifiable() {
+			counts[parts[i].commitmentKey()]++
+		}
+	}
+	most, tie := "", false
+	for key, n := range counts {
+		switch {
+		case n > counts[most]:
+			most, tie = key, false
+		case n == counts[most]:
+			tie = true
+		}
+	}
+	if most == "" {
+		return nil
+	}
+	var errs []*ShareError
+	for i := range parts {
+		var err error
+		switch {
+		case !parts[i].Verifiable():
+			err = ErrNotVerifiable
+		case tie:
+			err = ErrShareNoMajority
+		case parts[i].commitmentKey() != most:
+			err = ErrShareDisagreement
+		default:
+			err = parts[i].Verify()
+		}
+		if err != nil {
+			errs = append(errs, &ShareError{Index: i, Source: parts[i].source, Err: err})
+		}
+	}
+	return errs
+}
+
+// dropUnverified returns the parts which pass VerifyParts, if they are enough to combine, and the errors of those
+// which are dropped
+func dropUnverified(parts []Part) ([]Part, []*ShareError, error) {
+	bad := VerifyParts(parts)
+	if len(bad) == 0 {
+		return parts, nil, nil
+	}
+	dropped := make(map[int]bool, len(bad))
+	for _, e := range bad {
+		dropped[e.Index] = true
+	}
+	good := make([]Part, 0, len(parts)-len(bad))
+	for i := range parts {
+		if !dropped[i] {
+			good = append(good, parts[i])
+		}
+	}
+	if len(good) == 0 || len(good) < int(good[0].Threshold) {
+		threshold := parts[0].Threshold
+		if len(good) > 0 {
+			threshold = good[0].Threshold
+		}
+		errs := make([]error, len(bad))
+		for i, e := range bad {
+			errs[i] = e
+		}
+		return nil, nil, fmt.Errorf("%d verified secret shares, %d needed\n%w", len(good), threshold, errors.Join(errs...))
+	}
+	return good, bad, nil
+}
+
+// VerifyPartFile checks every block of a share file against its commitments, and returns the number of blocks
+func VerifyPartFile(in string) (blocks int, err error) {
+	file, closer, err := files.OpenInputFile(in)
+	if err != nil {
+		return 0, err
+	}
+	defer closer()
+	next, err := newPartReader(file)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		var p *Part
+		if p, err = next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return blocks, err
+		}
+		if err = p.Verify(); err != nil {
+			return blocks, fmt.Errorf("block %d: %w", p.Block, err)
+		}
+		blocks++
+	}
+	if blocks == 0 {
+		return 0, errors.New("no secret share found")
+	}
+	return blocks, nil
+}
@@ -0,0 +1,174 @@
+package sss
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func tamper(s string) string {
+	c := byte('A')
+	if s[0] == 'A' {
+		c = 'B'
+	}
+	return string(c) + s[1:]
+}
+
+func TestSplitVerifiable_RoundTrip(t *testing.T) {
+	for _, size := range []int{1, pedersenChunkSize, pedersenChunkSize + 1, 100} {
+		secret := bytes.Repeat([]byte{0xff}, size)
+		secret[0] = byte(size)
+		parts, err := SplitVerifiable(secret, 5, 3)
+		if err != nil {
+			t.Fatalf("size %d: SplitVerifiable failed: %v", size, err)
+		}
+		for i := range parts {
+			if len(parts[i].Commitments) != 3 {
+				t.Fatalf("size %d: expected 3 commitments, got %d", size, len(parts[i].Commitments))
+			}
+			if err = parts[i].Verify(); err != nil {
+				t.Errorf("size %d: part %d: %v", size, parts[i].Part, err)
+			}
+		}
+		got, _, err := Combine(parts[2:])
+		if err != nil {
+			t.Fatalf("size %d: Combine failed: %v", size, err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("size %d: expected %x, got %x", size, secret, got)
+		}
+		if _, _, err = Combine(parts[:2]); err == nil {
+			t.Errorf("size %d: expected error below the threshold", size)
+		}
+	}
+}
+
+func TestPart_Verify(t *testing.T) {
+	parts, err := SplitVerifiable([]byte("a secret with commitments"), 5, 3)
+	if err != nil {
+		t.Fatalf("SplitVerifiable failed: %v", err)
+	}
+	p := parts[0]
+	p.Payload = tamper(p.Payload)
+	if err = p.Verify(); !errors.Is(err, ErrShareMismatch) {
+		t.Errorf("expected ErrShareMismatch, got %v", err)
+	}
+	// A share of another part on the same polynomials still fails at the x of this part
+	share, _ := base64.URLEncoding.DecodeString(parts[0].Payload)
+	share[len(share)-1] = share[len(share)-1]%255 + 1
+	p = parts[0]
+	p.Payload = base64.URLEncoding.EncodeToString(share)
+	if err = p.Verify(); !errors.Is(err, ErrShareMismatch) {
+		t.Errorf("expected ErrShareMismatch for another x, got %v", err)
+	}
+	p = parts[0]
+	p.Commitments = append([]string{}, p.Commitments...)
+	p.Commitments[1], p.Commitments[2] = p.Commitments[2], p.Commitments[1]
+	if err = p.Verify(); !errors.Is(err, ErrShareMismatch) {
+		t.Errorf("expected ErrShareMismatch for other commitments, got %v", err)
+	}
+	p.Commitments[1] = base64.URLEncoding.EncodeToString(bytes.Repeat([]byte{0xff}, 32))
+	if err = p.Verify(); !errors.Is(err, errInvalidCommitment) {
+		t.Errorf("expected errInvalidCommitment, got %v", err)
+	}
+	p = parts[0]
+	p.Commitments = nil
+	if err = p.Verify(); !errors.Is(err, ErrNotVerifiable) {
+		t.Errorf("expected ErrNotVerifiable, got %v", err)
+	}
+}
+
+func TestCombine_DropsUnverifiedShares(t *testing.T) {
+	secret := []byte("a secret with commitments")
+	parts, err := SplitVerifiable(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("SplitVerifiable failed: %v", err)
+	}
+	for i := range parts {
+		parts[i].source = string(rune('a' + i))
+	}
+	// A corrupted share, and one whose holder changed the commitments to match a forged share
+	parts[1].Payload = tamper(parts[1].Payload)
+	forged := parts[3]
+	forged.Commitments = append([]string{}, forged.Commitments...)
+	forged.Commitments[0] = forged.Commitments[2]
+	parts[3] = forged
+
+	bad := VerifyParts(parts)
+	if len(bad) != 2 || bad[0].Source != "b" || !errors.Is(bad[0], ErrShareMismatch) ||
+		bad[1].Source != "d" || !errors.Is(bad[1], ErrShareDisagreement) {
+		t.Fatalf("expected shares b and d to fail, got %v", bad)
+	}
+	got, ignored, err := Combine(parts)
+	if err != nil {
+		t.Fatalf("Combine failed: %v", err)
+	}
+	if len(ignored) != 2 || ignored[0].Source != "b" || ignored[1].Source != "d" {
+		t.Errorf("expected shares b and d to be dropped, got %v", ignored)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("expected %q, got %q", secret, got)
+	}
+	var shareErr *ShareError
+	if _, _, err = Combine(parts[:3]); !errors.As(err, &shareErr) || shareErr.Source != "b" {
+		t.Errorf("expected error naming share b, got %v", err)
+	}
+
+	// Two shares on different commitments cannot tell which one is wrong
+	if bad = VerifyParts([]Part{parts[0], parts[3]}); len(bad) != 2 || !errors.Is(bad[0], ErrShareNoMajority) {
+		t.Errorf("expected no majority, got %v", bad)
+	}
+}
+
+func TestCombine_WithoutCommitments(t *testing.T) {
+	secret := []byte("a secret split without commitments")
+	parts, err := Split(secret, 3, 2)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	parts[0].Blocks = 1
+	b, err := marshalBinary(&parts[0])
+	if err != nil {
+		t.Fatalf("marshalBinary failed: %v", err)
+	}
+	if p, err := DecodePart(b); err != nil || p.Verifiable() {
+		t.Errorf("expected a part without commitments, got %+v, %v", p, err)
+	}
+	if VerifyParts(parts) != nil {
+		t.Error("expected nothing to verify")
+	}
+	got, _, err := Combine(parts[1:])
+	if err != nil || !bytes.Equal(got, secret) {
+		t.Errorf("expected %q, got %q, %v", secret, got, err)
+	}
+
+	// Shares without commitments are dropped among verifiable ones
+	verifiable, err := SplitVerifiable(secret, 3, 2)
+	if err != nil {
+		t.Fatalf("SplitVerifiable failed: %v", err)
+	}
+	if bad := VerifyParts([]Part{verifiable[0], verifiable[1], parts[2]}); len(bad) != 1 || !errors.Is(bad[0], ErrNotVerifiable) {
+		t.Errorf("expected the share without commitments to fail, got %v", bad)
+	}
+}
+
+func TestSplitVerifiable_Encodings(t *testing.T) {
+	parts, err := SplitVerifiable([]byte("a secret with commitments"), 3, 2)
+	if err != nil {
+		t.Fatalf("SplitVerifiable failed: %v", err)
+	}
+	parts[0].Blocks = 1
+	for _, encoding := range []Encoding{EncodingJson, EncodingBinary, EncodingArmor} {
+		b, err := encoding.marshal(&parts[0])
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", encoding, err)
+		}
+		if p, err := DecodePart(b); err != nil || len(p.Commitments) != 2 || p.Verify() != nil {
+			t.Errorf("%s: expected a verified part, got %+v, %v", encoding, p, err)
+		}
+	}
+	if _, err = EncodeMnemonic(&parts[0]); !errors.Is(err, ErrMnemonicVerifiable) {
+		t.Errorf("expected ErrMnemonicVerifiable, got %v", err)
+	}
+}
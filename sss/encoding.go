@@ -0,0 +1,547 @@
+package sss
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/makiuchi-d/gozxing"
+	zxingqr "github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/skip2/go-qrcode"
+)
+
+// Encoding is the format of a secret share file
+type Encoding string
+
+const (
+	EncodingJson     Encoding = "json"     // One JSON object per block
+	EncodingBinary   Encoding = "binary"   // Compact binary records, one per block
+	EncodingArmor    Encoding = "armor"    // Armored text to print or type, one block per armor
+	EncodingQR       Encoding = "qr"       // PNG image of a QR code holding the armored text, for one block only
+	EncodingMnemonic Encoding = "mnemonic" // Words to write down, for one block of a short secret only
+)
+
+func ParseEncoding(name string) (Encoding, error) {
+	switch e := Encoding(strings.ToLower(strings.TrimSpace(name))); e {
+	case EncodingJson, EncodingBinary, EncodingArmor, EncodingQR, EncodingMnemonic:
+		return e, nil
+	default:
+		return "", fmt.Errorf("unknown encoding of secret shares: %s", name)
+	}
+}
+
+// Ext returns the extension of the share files of e
+func (e Encoding) Ext() string {
+	switch e {
+	case EncodingBinary:
+		return ".bin"
+	case EncodingArmor:
+		return ".txt"
+	case EncodingQR:
+		return ".png"
+	case EncodingMnemonic:
+		return ".words.txt"
+	default:
+		return ".json"
+	}
+}
+
+// separator returns what is written between the blocks of a share file of e
+func (e Encoding) separator() string {
+	switch e {
+	case EncodingJson:
+		return "\n\n"
+	case EncodingArmor:
+		return "\n"
+	default:
+		return ""
+	}
+}
+
+// marshal returns the encoded block of p
+func (e Encoding) marshal(p *Part) ([]byte, error) {
+	switch e {
+	case EncodingBinary:
+		return marshalBinary(p)
+	case EncodingArmor:
+		return marshalArmor(p)
+	case EncodingQR:
+		if p.Blocks > 1 {
+			return nil, fmt.Errorf("a QR code holds one block of a share, not %d blocks", p.Blocks)
+		}
+		text, err := marshalArmor(p)
+		if err != nil {
+			return nil, err
+		}
+		var png []byte
+		if png, err = qrcode.Encode(string(text), qrcode.Medium, qrModuleSize); err != nil {
+			return nil, fmt.Errorf("QR code: %w", err)
+		}
+		return png, nil
+	case EncodingMnemonic:
+		return marshalWords(p)
+	default:
+		return json.Marshal(p)
+	}
+}
+
+// qrModuleSize is the negative size of the QR code images, which makes them 8 pixels per module
+const qrModuleSize = -8
+
+// The binary encoding of a block is
+//
+//	magic "FSS" version 1
+//	part, parts and threshold as bytes
+//	block and blocks as uvarints
+//	timestamp as a varint of unix nanoseconds
+//	digest and payload as uvarint lengths of their raw bytes
+//	the optional fields of the part, each as a tag byte and a uvarint length of its value, ending with tag 0
+//	CRC-32 (IEEE) of all of the above, big endian
+//
+// An optional field is written only if the part has it, and a commitment is one field each, in their order.
+// Fields of unknown tags are rejected, rather than dropped.
+var binaryMagic = []byte("FSS")
+
+const binaryVersion = 1
+
+// Tags of the optional fields of the binary encoding
+const (
+	binaryTagEnd        = 0
+	binaryTagCommitment = 1 // Raw bytes of a commitment
+	binaryTagGeneration = 2 // Generation as a uvarint
+	binaryTagXs         = 3 // Raw bytes of the xs
+	binaryTagHolders    = 4 // Parts of the holders as bytes
+)
+
+// appendBinaryField appends the field of tag and value to b
+func appendBinaryField(b []byte, tag byte, value []byte) []byte {
+	b = append(b, tag)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func marshalBinary(p *Part) ([]byte, error) {
+	if p.Part < 1 || p.Part > 255 {
+		return nil, fmt.Errorf("invalid part number %d", p.Part)
+	}
+	digest, err := base64.URLEncoding.DecodeString(p.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest: %w", err)
+	}
+	var payload []byte
+	if payload, err = base64.URLEncoding.DecodeString(p.Payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	var xs []byte
+	if xs, err = base64.URLEncoding.DecodeString(p.Xs); err != nil {
+		return nil, fmt.Errorf("invalid xs: %w", err)
+	}
+	holders := make([]byte, len(p.Holders))
+	for i, h := range p.Holders {
+		if h < 1 || h > 255 {
+			return nil, fmt.Errorf("invalid holder part number %d", h)
+		}
+		holders[i] = byte(h)
+	}
+	var commitments [][]byte
+	if commitments, err = p.commitments(); err != nil {
+		return nil, err
+	}
+	b := append([]byte{}, binaryMagic...)
+	b = append(b, binaryVersion, byte(p.Part), p.Parts, p.Threshold)
+	b = binary.AppendUvarint(b, uint64(p.Block))
+	b = binary.AppendUvarint(b, uint64(p.Blocks))
+	b = binary.AppendVarint(b, p.Timestamp.UnixNano())
+	b = binary.AppendUvarint(b, uint64(len(digest)))
+	b = append(b, digest...)
+	b = binary.AppendUvarint(b, uint64(len(payload)))
+	b = append(b, payload...)
+	for _, c := range commitments {
+		b = appendBinaryField(b, binaryTagCommitment, c)
+	}
+	if p.Generation > 0 {
+		b = appendBinaryField(b, binaryTagGeneration, binary.AppendUvarint(nil, uint64(p.Generation)))
+	}
+	if len(xs) > 0 {
+		b = appendBinaryField(b, binaryTagXs, xs)
+	}
+	if len(holders) > 0 {
+		b = appendBinaryField(b, binaryTagHolders, holders)
+	}
+	b = append(b, binaryTagEnd)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b)), nil
+}
+
+var errInvalidBinary = errors.New("invalid binary secret share")
+
+// crcReader reads a binary block while computing its checksum
+type crcReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (c *crcReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		_, _ = c.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (c *crcReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	_, _ = c.crc.Write(p[:n])
+	return n, err
+}
+
+func (c *crcReader) bytes() ([]byte, error) {
+	n, err := binary.ReadUvarint(c)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxScannerTokenSize {
+		return nil, errInvalidBinary
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(c, b)
+	return b, err
+}
+
+// unmarshalBinary reads the next binary block from r, or returns io.EOF at the end
+func unmarshalBinary(r *bufio.Reader) (*Part, error) {
+	c := &crcReader{r: r, crc: crc32.NewIEEE()}
+	head := make([]byte, len(binaryMagic)+4)
+	if _, err := io.ReadFull(c, head); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errInvalidBinary
+		}
+		return nil, err
+	}
+	if !bytes.Equal(head[:len(binaryMagic)], binaryMagic) || head[len(binaryMagic)] != binaryVersion {
+		return nil, errInvalidBinary
+	}
+	p := &Part{Part: int(head[4]), Parts: head[5], Threshold: head[6]}
+	fail := func(err error) (*Part, error) {
+		if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = errInvalidBinary
+		}
+		return nil, err
+	}
+	var block, blocks uint64
+	var nano int64
+	var digest, payload []byte
+	var err error
+	if block, err = binary.ReadUvarint(c); err != nil {
+		return fail(err)
+	}
+	if blocks, err = binary.ReadUvarint(c); err != nil {
+		return fail(err)
+	}
+	if nano, err = binary.ReadVarint(c); err != nil {
+		return fail(err)
+	}
+	if digest, err = c.bytes(); err != nil {
+		return fail(err)
+	}
+	if payload, err = c.bytes(); err != nil {
+		return fail(err)
+	}
+	for {
+		var tag byte
+		if tag, err = c.ReadByte(); err != nil {
+			return fail(err)
+		}
+		if tag == binaryTagEnd {
+			break
+		}
+		var value []byte
+		if value, err = c.bytes(); err != nil {
+			return fail(err)
+		}
+		switch tag {
+		case binaryTagCommitment:
+			if len(p.Commitments) == math.MaxUint8 {
+				return fail(nil)
+			}
+			p.Commitments = append(p.Commitments, base64.URLEncoding.EncodeToString(value))
+		case binaryTagGeneration:
+			generation, n := binary.Uvarint(value)
+			if n <= 0 || n != len(value) || generation > math.MaxInt32 {
+				return fail(nil)
+			}
+			p.Generation = int(generation)
+		case binaryTagXs:
+			if len(value) > math.MaxUint8 {
+				return fail(nil)
+			}
+			p.Xs = base64.URLEncoding.EncodeToString(value)
+		case binaryTagHolders:
+			if len(value) > math.MaxUint8 {
+				return fail(nil)
+			}
+			for _, h := range value {
+				p.Holders = append(p.Holders, int(h))
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown field %d", errInvalidBinary, tag)
+		}
+	}
+	sum := c.crc.Sum32()
+	var crc [4]byte
+	if _, err = io.ReadFull(r, crc[:]); err != nil {
+		return fail(err)
+	}
+	if binary.BigEndian.Uint32(crc[:]) != sum {
+		return nil, fmt.Errorf("%w: checksum mismatch", errInvalidBinary)
+	}
+	if block > math.MaxInt32 || blocks > math.MaxInt32 {
+		return fail(nil)
+	}
+	p.Block, p.Blocks = int(block), int(blocks)
+	p.Timestamp = time.Unix(0, nano)
+	p.Digest = base64.URLEncoding.EncodeToString(digest)
+	p.Payload = base64.URLEncoding.EncodeToString(payload)
+	return p, nil
+}
+
+// The armored encoding of a block is the base32 text of its binary encoding between the markers,
+// after informative headers which are ignored when it is read:
+//
+//	-----BEGIN FORTIFY SECRET SHARE-----
+//	Part: 1/5
+//	Threshold: 3
+//
+//	FVGV GAIB AUDA EAQO ZLJL 6N4Z 7ZAY 7KX6 Q2LM
+//	...
+//	-----END FORTIFY SECRET SHARE-----
+//
+// Each line ends with a group of four characters holding a checksum of the line and its number,
+// so that a typo or a swapped line is found on its line.
+const (
+	armorBegin     = "-----BEGIN FORTIFY SECRET SHARE-----"
+	armorEnd       = "-----END FORTIFY SECRET SHARE-----"
+	armorGroupSize = 4
+	armorLineSize  = 8 * armorGroupSize
+)
+
+var armorBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// armorTypos maps the characters which are not base32 to those they are typed for
+var armorTypos = strings.NewReplacer("0", "O", "1", "I", "8", "B")
+
+// armorMarker returns line in upper case without dashes and spaces and with typos mapped, to tell the markers as typed
+func armorMarker(line string) string {
+	return armorTypos.Replace(strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(line)))
+}
+
+func marshalArmor(p *Part) ([]byte, error) {
+	b, err := marshalBinary(p)
+	if err != nil {
+		return nil, err
+	}
+	text := armorBase32.EncodeToString(b)
+	var buf bytes.Buffer
+	buf.WriteString(armorBegin + "\n")
+	_, _ = fmt.Fprintf(&buf, "Part: %d/%d\n", p.Part, p.Parts)
+	_, _ = fmt.Fprintf(&buf, "Threshold: %d\n", p.Threshold)
+	if p.Blocks > 1 {
+		_, _ = fmt.Fprintf(&buf, "Block: %d/%d\n", p.Block, p.Blocks)
+	}
+	if p.Generation > 0 {
+		_, _ = fmt.Fprintf(&buf, "Generation: %d\n", p.Generation)
+	}
+	if len(p.Holders) > 0 {
+		_, _ = fmt.Fprintf(&buf, "Holders: %s\n", strings.Trim(fmt.Sprint(p.Holders), "[]"))
+	}
+	_, _ = fmt.Fprintf(&buf, "Created: %s\n\n", p.Timestamp.Format(time.RFC3339))
+	for no := 1; len(text) > 0; no++ {
+		line := text[:min(armorLineSize, len(text))]
+		text = text[len(line):]
+		for i := 0; i < len(line); i += armorGroupSize {
+			buf.WriteString(line[i:min(i+armorGroupSize, len(line))])
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(armorLineChecksum(no, line) + "\n")
+	}
+	buf.WriteString(armorEnd + "\n")
+	return buf.Bytes(), nil
+}
+
+// armorLineChecksum returns the 20 high bits of the CRC-32 of the line and its number in base32
+func armorLineChecksum(no int, line string) string {
+	sum := crc32.ChecksumIEEE(fmt.Appendf(nil, "%d:%s", no, line)) >> 12
+	alphabet := "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+	return string([]byte{alphabet[sum>>15&31], alphabet[sum>>10&31], alphabet[sum>>5&31], alphabet[sum&31]})
+}
+
+// unmarshalArmor reads the next armored block from the lines of s, or returns io.EOF if there is none.
+// It takes the text as typed: in any case, with any spaces or dashes between the groups of a line,
+// and with 0, 1 and 8 for O, I and B.
+func unmarshalArmor(s *bufio.Scanner, lineNo *int) (*Part, error) {
+	var text strings.Builder
+	inside := false
+	no := 0
+	for s.Scan() {
+		*lineNo++
+		line := strings.TrimSpace(s.Text())
+		switch marker := armorMarker(line); {
+		case marker == armorMarker(armorBegin):
+			if inside {
+				return nil, fmt.Errorf("line %d: unexpected begin of armor", *lineNo)
+			}
+			inside = true
+		case !inside:
+			// Notes around the armors are ignored
+		case marker == armorMarker(armorEnd):
+			b, err := armorBase32.DecodeString(text.String())
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid armored share: %w", *lineNo, err)
+			}
+			p, err := unmarshalBinary(bufio.NewReader(bytes.NewReader(b)))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", *lineNo, err)
+			}
+			return p, nil
+		case line == "" || strings.Contains(line, ":"):
+			// Headers are informative only
+		default:
+			fields := strings.Fields(strings.ReplaceAll(armorTypos.Replace(strings.ToUpper(line)), "-", " "))
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: missing checksum group", *lineNo)
+			}
+			no++
+			data := strings.Join(fields[:len(fields)-1], "")
+			if fields[len(fields)-1] != armorLineChecksum(no, data) {
+				return nil, fmt.Errorf("line %d: checksum mismatch, check line %d of the armored share for typos", *lineNo, no)
+			}
+			text.WriteString(data)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if inside {
+		return nil, fmt.Errorf("line %d: missing end of armor", *lineNo)
+	}
+	return nil, io.EOF
+}
+
+// partReader reads the blocks of a share file one by one, and returns io.EOF after the last one
+type partReader func() (*Part, error)
+
+// newPartReader detects the encoding of a share file from its start. Text other than JSON is taken as armored.
+func newPartReader(r io.Reader) (partReader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(512)
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG")):
+		text, err := decodeQR(br)
+		if err != nil {
+			return nil, err
+		}
+		lineNo := 0
+		scanner := bufio.NewScanner(strings.NewReader(text))
+		return func() (*Part, error) {
+			return unmarshalArmor(scanner, &lineNo)
+		}, nil
+	case bytes.HasPrefix(head, binaryMagic):
+		return func() (*Part, error) {
+			if _, err := br.Peek(1); err != nil {
+				return nil, err
+			}
+			return unmarshalBinary(br)
+		}, nil
+	}
+	if looksLikeMnemonic(head) {
+		return nil, errors.New("a mnemonic share is combined with 'sss combine --mnemonic'")
+	}
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, maxScannerTokenSize), maxScannerTokenSize)
+	if first := bytes.TrimLeft(head, " \t\r\n"); len(first) > 0 && first[0] == '{' {
+		return func() (*Part, error) {
+			for scanner.Scan() {
+				if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+					p := &Part{}
+					if err := json.Unmarshal(line, p); err != nil {
+						return nil, err
+					}
+					return p, nil
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}, nil
+	}
+	lineNo := 0
+	return func() (*Part, error) {
+		return unmarshalArmor(scanner, &lineNo)
+	}, nil
+}
+
+// maxQRImageSize and maxQRImageSide bound the PNG images of QR codes, which are far smaller when written by split
+const (
+	maxQRImageSize = 16 << 20
+	maxQRImageSide = 8192
+)
+
+// decodeQR returns the armored text of the QR code in the PNG image of r, as written by split or scanned to a file
+func decodeQR(r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxQRImageSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxQRImageSize {
+		return "", fmt.Errorf("QR code image larger than %d bytes", maxQRImageSize)
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("QR code image: %w", err)
+	}
+	if config.Width > maxQRImageSide || config.Height > maxQRImageSide {
+		return "", fmt.Errorf("QR code image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("QR code image: %w", err)
+	}
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", fmt.Errorf("QR code image: %w", err)
+	}
+	// The images written by split hold nothing but the code, which the detector of photos now and then misses
+	reader := zxingqr.NewQRCodeReader()
+	result, err := reader.Decode(bitmap, map[gozxing.DecodeHintType]any{gozxing.DecodeHintType_PURE_BARCODE: true})
+	if err != nil {
+		if result, err = reader.Decode(bitmap, map[gozxing.DecodeHintType]any{gozxing.DecodeHintType_TRY_HARDER: true}); err != nil {
+			return "", fmt.Errorf("no QR code found in the image: %w", err)
+		}
+	}
+	return result.GetText(), nil
+}
+
+// DecodePart decodes the first block of a share in any encoding
+func DecodePart(data []byte) (*Part, error) {
+	next, err := newPartReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	p, err := next()
+	if err == io.EOF {
+		return nil, errors.New("no secret share found")
+	}
+	return p, err
+}
@@ -0,0 +1,138 @@
+package sss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testParts(t *testing.T) []Part {
+	t.Helper()
+	parts, err := Split([]byte("a secret worth a paper backup"), 3, 2)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	for i := range parts {
+		parts[i].Block, parts[i].Blocks = 1, 1
+		// The binary encoding keeps the instant, not the location of the timestamp
+		parts[i].Timestamp = time.Unix(0, parts[i].Timestamp.UnixNano())
+	}
+	return parts
+}
+
+func TestEncoding_RoundTrip(t *testing.T) {
+	parts := testParts(t)
+	for _, encoding := range []Encoding{EncodingJson, EncodingBinary, EncodingArmor} {
+		var data []byte
+		for i := range parts {
+			b, err := encoding.marshal(&parts[i])
+			if err != nil {
+				t.Fatalf("%s: marshal failed: %v", encoding, err)
+			}
+			if i > 0 {
+				data = append(data, encoding.separator()...)
+			}
+			data = append(data, b...)
+		}
+		next, err := newPartReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: newPartReader failed: %v", encoding, err)
+		}
+		for i := range parts {
+			p, err := next()
+			if err != nil {
+				t.Fatalf("%s: block %d: %v", encoding, i, err)
+			}
+			if !p.Timestamp.Equal(parts[i].Timestamp) {
+				t.Errorf("%s: expected timestamp %v, got %v", encoding, parts[i].Timestamp, p.Timestamp)
+			}
+			p.Timestamp = parts[i].Timestamp
+			if !reflect.DeepEqual(*p, parts[i]) {
+				t.Errorf("%s: expected %+v, got %+v", encoding, parts[i], *p)
+			}
+		}
+		if _, err = next(); err != io.EOF {
+			t.Errorf("%s: expected io.EOF after the last block, got %v", encoding, err)
+		}
+	}
+}
+
+func TestEncoding_QRCode(t *testing.T) {
+	parts := testParts(t)
+	data, err := EncodingQR.marshal(&parts[1])
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	p, err := DecodePart(data)
+	if err != nil {
+		t.Fatalf("DecodePart of a QR code image failed: %v", err)
+	}
+	p.Timestamp = parts[1].Timestamp
+	if !reflect.DeepEqual(*p, parts[1]) {
+		t.Errorf("expected %+v, got %+v", parts[1], *p)
+	}
+}
+
+func TestEncoding_ArmorAsTyped(t *testing.T) {
+	parts := testParts(t)
+	armor, err := marshalArmor(&parts[0])
+	if err != nil {
+		t.Fatalf("marshalArmor failed: %v", err)
+	}
+	// Typed in lower case, with dashes between the groups and digits for similar letters
+	typed := strings.ToLower(string(armor))
+	typed = strings.NewReplacer(" ", "-", "o", "0", "i", "1", "b", "8").Replace(typed)
+	p, err := DecodePart([]byte("Share of Alice, kept in the safe\n\n" + typed))
+	if err != nil {
+		t.Fatalf("DecodePart failed: %v", err)
+	}
+	if p.Payload != parts[0].Payload || p.Digest != parts[0].Digest {
+		t.Errorf("expected %+v, got %+v", parts[0], *p)
+	}
+
+	// A typo is found on its line
+	lines := strings.Split(string(armor), "\n")
+	i := slices.Index(lines, "") + 1
+	c := "A"
+	if lines[i][0] == 'A' {
+		c = "B"
+	}
+	lines[i] = c + lines[i][1:]
+	if _, err = DecodePart([]byte(strings.Join(lines, "\n"))); err == nil || !strings.Contains(err.Error(), "line 1 of the armored share") {
+		t.Errorf("expected checksum mismatch on line 1, got %v", err)
+	}
+}
+
+func TestEncoding_Invalid(t *testing.T) {
+	parts := testParts(t)
+	b, err := marshalBinary(&parts[0])
+	if err != nil {
+		t.Fatalf("marshalBinary failed: %v", err)
+	}
+	corrupted := bytes.Clone(b)
+	corrupted[len(corrupted)/2] ^= 1
+	// A field of a later version is not dropped
+	unknown := append(bytes.Clone(b[:len(b)-5]), 9, 1, 0, binaryTagEnd)
+	unknown = binary.BigEndian.AppendUint32(unknown, crc32.ChecksumIEEE(unknown))
+	for name, data := range map[string][]byte{
+		"corrupted binary": corrupted,
+		"truncated binary": b[:len(b)-1],
+		"unknown field":    unknown,
+		"truncated armor":  []byte(armorBegin + "\nPart: 1/3\n\nAAAA BBBB"),
+		"no share":         []byte("just a note\n"),
+		"QR code image":    []byte("\x89PNG\r\n\x1a\n"),
+	} {
+		if p, err := DecodePart(data); err == nil {
+			t.Errorf("%s: expected error, got %+v", name, p)
+		}
+	}
+	if _, err = ParseEncoding("cbor"); err == nil {
+		t.Error("expected error for unknown encoding")
+	}
+}
@@ -0,0 +1,342 @@
+package sss
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/i3ash/fortify/utils"
+)
+
+// wordlist is the English word list of BIP-39, where every word is told by its first four letters
+//
+//go:embed wordlist.txt
+var wordlist string
+
+var (
+	mnemonicWords = strings.Fields(wordlist)
+	mnemonicIndex = func() map[string]uint16 {
+		index := make(map[string]uint16, len(mnemonicWords))
+		for i, word := range mnemonicWords {
+			index[mnemonicPrefix(word)] = uint16(i)
+		}
+		return index
+	}()
+)
+
+func mnemonicPrefix(word string) string {
+	return word[:min(4, len(word))]
+}
+
+// lookupWord returns the value of a word, which may be abbreviated to its first four letters
+func lookupWord(word string) (uint16, bool) {
+	word = strings.ToLower(word)
+	v, ok := mnemonicIndex[mnemonicPrefix(word)]
+	return v, ok && strings.HasPrefix(mnemonicWords[v], word)
+}
+
+// A mnemonic holds a share of one block in words of 11 bits:
+//
+//	part, parts and threshold as 8 bits each
+//	length of the secret as 7 bits
+//	the first 24 bits of the digest of the secret, to tell the shares of another secret
+//	the share, after as many zero bits as it takes to fill its last word
+//	three words of a Reed-Solomon checksum over GF(2048), which detects any three mistyped words
+const (
+	mnemonicWordBits      = 11
+	mnemonicHeadWords     = 5
+	mnemonicChecksumWords = 3
+	mnemonicSizeBits      = 7
+	mnemonicDigestBits    = 24
+	// MaxMnemonicSecretSize is the largest secret whose shares are written as mnemonics
+	MaxMnemonicSecretSize = 64
+)
+
+// mnemonicCustomization starts every checksum, so that the words of other schemes with this word list fail it
+var mnemonicCustomization = []uint16{'f', 'o', 'r', 't', 'i', 'f', 'y'}
+
+// Mnemonic is a secret share read from its words
+type Mnemonic struct {
+	Part      int
+	Parts     uint8
+	Threshold uint8
+	Digest    uint32 // The first 24 bits of the digest of the secret
+	Share     Share
+}
+
+// digestBits returns the first 24 bits of a digest of utils.ComputeDigest
+func digestBits(digest string) (uint32, error) {
+	b, err := base64.URLEncoding.DecodeString(digest)
+	if err != nil || len(b) < 4 {
+		return 0, fmt.Errorf("invalid digest %q", digest)
+	}
+	return binary.BigEndian.Uint32(b) >> (32 - mnemonicDigestBits), nil
+}
+
+var ErrMnemonicVerifiable = errors.New("a mnemonic does not hold a verifiable share, which carries commitments")
+
+// EncodeMnemonic returns the words of the share of p, which is one block of a secret of MaxMnemonicSecretSize bytes at most
+func EncodeMnemonic(p *Part) ([]string, error) {
+	if p.Blocks > 1 {
+		return nil, fmt.Errorf("a mnemonic holds one block of a share, not %d blocks", p.Blocks)
+	}
+	if p.Part < 1 || p.Part > 255 {
+		return nil, fmt.Errorf("invalid part number %d", p.Part)
+	}
+	if p.Generation > 0 {
+		return nil, errors.New("a mnemonic does not record the generation of a refreshed share")
+	}
+	if p.Verifiable() {
+		return nil, ErrMnemonicVerifiable
+	}
+	share, err := base64.URLEncoding.DecodeString(p.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	if len(share) < 2 || len(share) > MaxMnemonicSecretSize+1 {
+		return nil, fmt.Errorf("a mnemonic holds the share of a secret of 1 to %d bytes, not %d bytes",
+			MaxMnemonicSecretSize, len(share)-1)
+	}
+	var digest uint32
+	if digest, err = digestBits(p.Digest); err != nil {
+		return nil, err
+	}
+	w := &bitWriter{}
+	w.write(uint64(p.Part), 8)
+	w.write(uint64(p.Parts), 8)
+	w.write(uint64(p.Threshold), 8)
+	w.write(uint64(len(share)-1), mnemonicSizeBits)
+	w.write(uint64(digest), mnemonicDigestBits)
+	w.write(0, shareBits(len(share))-len(share)*8)
+	for _, b := range share {
+		w.write(uint64(b), 8)
+	}
+	values := append(w.values, rsChecksum(w.values)...)
+	words := make([]string, len(values))
+	for i, v := range values {
+		words[i] = mnemonicWords[v]
+	}
+	return words, nil
+}
+
+// DecodeMnemonic reads a share from its words, which may be abbreviated to their first four letters
+func DecodeMnemonic(words []string) (*Mnemonic, error) {
+	if len(words) < wordCount(2) || len(words) > wordCount(MaxMnemonicSecretSize+1) {
+		return nil, fmt.Errorf("a mnemonic has %d to %d words, not %d", wordCount(2), wordCount(MaxMnemonicSecretSize+1), len(words))
+	}
+	values := make([]uint16, len(words))
+	for i, word := range words {
+		v, ok := lookupWord(word)
+		if !ok {
+			return nil, fmt.Errorf("word %d %q is not in the word list", i+1, word)
+		}
+		values[i] = v
+	}
+	if !rsVerify(values) {
+		return nil, errors.New("checksum mismatch, check the words for typos")
+	}
+	r := &bitReader{values: values[:len(values)-mnemonicChecksumWords]}
+	m := &Mnemonic{Part: int(r.read(8)), Parts: uint8(r.read(8)), Threshold: uint8(r.read(8))}
+	size := int(r.read(mnemonicSizeBits)) + 1
+	m.Digest = uint32(r.read(mnemonicDigestBits))
+	if size < 2 || wordCount(size) != len(words) {
+		return nil, fmt.Errorf("%d words for the share of a secret of %d bytes", len(words), size-1)
+	}
+	if r.read(shareBits(size)-size*8) != 0 {
+		return nil, errors.New("invalid padding of the share")
+	}
+	m.Share = make(Share, size)
+	for i := range m.Share {
+		m.Share[i] = byte(r.read(8))
+	}
+	return m, nil
+}
+
+// mnemonicLineWords is the number of words per line of a mnemonic file
+const mnemonicLineWords = 8
+
+// marshalWords returns the words of the mnemonic of p in lines, after a comment for the custodian
+func marshalWords(p *Part) ([]byte, error) {
+	words, err := EncodeMnemonic(p)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, "# Part %d/%d, threshold %d, created %s\n",
+		p.Part, p.Parts, p.Threshold, p.Timestamp.Format(time.RFC3339))
+	for i := 0; i < len(words); i += mnemonicLineWords {
+		buf.WriteString(strings.Join(words[i:min(i+mnemonicLineWords, len(words))], " ") + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseMnemonic reads a share from the words of text, where lines starting with # are comments
+func ParseMnemonic(text string) (*Mnemonic, error) {
+	var words []string
+	for line := range strings.Lines(text) {
+		if line = strings.TrimSpace(line); !strings.HasPrefix(line, "#") {
+			words = append(words, strings.Fields(line)...)
+		}
+	}
+	return DecodeMnemonic(words)
+}
+
+// looksLikeMnemonic reports whether the first line of text which is not a comment holds words of a mnemonic
+func looksLikeMnemonic(text []byte) bool {
+	for line := range strings.Lines(string(text)) {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for _, word := range fields {
+			if _, ok := lookupWord(word); !ok {
+				return false
+			}
+		}
+		return len(fields) >= 4
+	}
+	return false
+}
+
+// shareBits returns the number of bits of the whole words holding a share of size bytes
+func shareBits(size int) int {
+	return (size*8 + mnemonicWordBits - 1) / mnemonicWordBits * mnemonicWordBits
+}
+
+// wordCount returns the number of words of a mnemonic holding a share of size bytes
+func wordCount(size int) int {
+	return mnemonicHeadWords + shareBits(size)/mnemonicWordBits + mnemonicChecksumWords
+}
+
+// Compatible returns an error unless m is another share of the secret of first
+func (m *Mnemonic) Compatible(first *Mnemonic) error {
+	switch {
+	case m.Threshold != first.Threshold || m.Digest != first.Digest || len(m.Share) != len(first.Share):
+		return errors.New("share of another secret")
+	case m.Share[len(m.Share)-1] == first.Share[len(first.Share)-1]:
+		return ErrDuplicatedShare
+	default:
+		return nil
+	}
+}
+
+// CombineMnemonics recovers the secret from the shares of mnemonics, and checks it against their digest
+func CombineMnemonics(ms []*Mnemonic) ([]byte, error) {
+	if len(ms) == 0 {
+		return nil, ErrShareCountNotEnough
+	}
+	if len(ms) < int(ms[0].Threshold) {
+		return nil, fmt.Errorf("need %d shares, got %d", ms[0].Threshold, len(ms))
+	}
+	shares := make([]Share, len(ms))
+	for i, m := range ms {
+		for _, prev := range ms[:i] {
+			if err := m.Compatible(prev); err != nil {
+				return nil, fmt.Errorf("share %d: %w", i+1, err)
+			}
+		}
+		shares[i] = m.Share
+	}
+	secret, err := CombineFromShares(shares)
+	if err != nil {
+		return nil, err
+	}
+	if digest, _ := digestBits(utils.ComputeDigest(secret)); digest != ms[0].Digest {
+		clear(secret)
+		return nil, errors.New("secret digest mismatch")
+	}
+	return secret, nil
+}
+
+// bitWriter packs values into words of 11 bits, from the most significant bit
+type bitWriter struct {
+	values []uint16
+	acc    uint64
+	n      int
+}
+
+func (w *bitWriter) write(v uint64, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		w.acc = w.acc<<1 | v>>i&1
+		if w.n++; w.n == mnemonicWordBits {
+			w.values = append(w.values, uint16(w.acc))
+			w.acc, w.n = 0, 0
+		}
+	}
+}
+
+// bitReader unpacks values from words of 11 bits, from the most significant bit
+type bitReader struct {
+	values []uint16
+	pos    int
+}
+
+func (r *bitReader) read(bits int) (v uint64) {
+	for range bits {
+		word := r.values[r.pos/mnemonicWordBits]
+		v = v<<1 | uint64(word>>(mnemonicWordBits-1-r.pos%mnemonicWordBits)&1)
+		r.pos++
+	}
+	return
+}
+
+// GF(2048) with the primitive polynomial x^11 + x^2 + 1, where the words are the symbols of the checksum
+var gf2048Exp, gf2048Log = func() (exp [2 * 2047]uint16, log [2048]uint16) {
+	x := uint16(1)
+	for i := range 2047 {
+		exp[i], exp[i+2047] = x, x
+		log[x] = uint16(i)
+		if x <<= 1; x&0x800 != 0 {
+			x ^= 0x805
+		}
+	}
+	return
+}()
+
+func gf2048Mul(a, b uint16) uint16 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf2048Exp[int(gf2048Log[a])+int(gf2048Log[b])]
+}
+
+// rsGenerator is (x - a)(x - a^2)(x - a^3) with the coefficients from x^2 down to 1, leaving out x^3
+var rsGenerator = func() []uint16 {
+	g := []uint16{1}
+	for i := 1; i <= mnemonicChecksumWords; i++ {
+		next := make([]uint16, len(g)+1)
+		for j, c := range g {
+			next[j] ^= c
+			next[j+1] ^= gf2048Mul(c, gf2048Exp[i])
+		}
+		g = next
+	}
+	return g[1:]
+}()
+
+// rsChecksum returns the checksum words which make values a codeword: the remainder of the customization
+// and values, times x^3, divided by the generator
+func rsChecksum(values []uint16) []uint16 {
+	r := make([]uint16, mnemonicChecksumWords)
+	for _, v := range append(append([]uint16{}, mnemonicCustomization...), values...) {
+		factor := v ^ r[0]
+		copy(r, r[1:])
+		r[len(r)-1] = 0
+		for i, g := range rsGenerator {
+			r[i] ^= gf2048Mul(g, factor)
+		}
+	}
+	return r
+}
+
+// rsVerify reports whether values end with their checksum words
+func rsVerify(values []uint16) bool {
+	n := len(values) - mnemonicChecksumWords
+	return slices.Equal(values[n:], rsChecksum(values[:n]))
+}
@@ -0,0 +1,123 @@
+package sss
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMnemonic_RoundTrip(t *testing.T) {
+	for _, size := range []int{1, 16, 32, 33, MaxMnemonicSecretSize} {
+		secret := bytes.Repeat([]byte{0xa5}, size)
+		parts, err := Split(secret, 5, 3)
+		if err != nil {
+			t.Fatalf("Split failed: %v", err)
+		}
+		var ms []*Mnemonic
+		for i := range parts[:3] {
+			words, err := EncodeMnemonic(&parts[i])
+			if err != nil {
+				t.Fatalf("size %d: EncodeMnemonic failed: %v", size, err)
+			}
+			if len(words) != wordCount(size+1) {
+				t.Errorf("size %d: expected %d words, got %d", size, wordCount(size+1), len(words))
+			}
+			// Abbreviated words are as good as whole ones
+			if i == 0 {
+				for j, word := range words {
+					words[j] = strings.ToUpper(mnemonicPrefix(word))
+				}
+			}
+			m, err := DecodeMnemonic(words)
+			if err != nil {
+				t.Fatalf("size %d: DecodeMnemonic failed: %v", size, err)
+			}
+			if m.Part != parts[i].Part || m.Parts != 5 || m.Threshold != 3 {
+				t.Errorf("size %d: unexpected header %+v", size, m)
+			}
+			ms = append(ms, m)
+		}
+		got, err := CombineMnemonics(ms)
+		if err != nil {
+			t.Fatalf("size %d: CombineMnemonics failed: %v", size, err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("size %d: expected %x, got %x", size, secret, got)
+		}
+		if _, err = CombineMnemonics(ms[:2]); err == nil {
+			t.Errorf("size %d: expected error below the threshold", size)
+		}
+	}
+}
+
+func TestMnemonic_MistypedWord(t *testing.T) {
+	parts := testParts(t)
+	words, err := EncodeMnemonic(&parts[0])
+	if err != nil {
+		t.Fatalf("EncodeMnemonic failed: %v", err)
+	}
+	for i, word := range words {
+		v, _ := lookupWord(word)
+		typo := append([]string{}, words...)
+		typo[i] = mnemonicWords[(int(v)+1+i*97)%len(mnemonicWords)]
+		if m, err := DecodeMnemonic(typo); err == nil {
+			t.Errorf("word %d: expected checksum mismatch, got %+v", i+1, m)
+		}
+	}
+	if _, err = DecodeMnemonic(words[:len(words)-1]); err == nil {
+		t.Error("expected error for a missing word")
+	}
+	if _, err = DecodeMnemonic(append(words[:1:1], append([]string{"fortify"}, words[2:]...)...)); err == nil {
+		t.Error("expected error for a word out of the word list")
+	}
+}
+
+func TestMnemonic_ParseAndCompatible(t *testing.T) {
+	parts := testParts(t)
+	text, err := marshalWords(&parts[0])
+	if err != nil {
+		t.Fatalf("marshalWords failed: %v", err)
+	}
+	if !looksLikeMnemonic(text) {
+		t.Errorf("expected a mnemonic: %s", text)
+	}
+	if _, err = DecodePart(text); err == nil || !strings.Contains(err.Error(), "--mnemonic") {
+		t.Errorf("expected a hint to combine with --mnemonic, got %v", err)
+	}
+	first, err := ParseMnemonic(string(text))
+	if err != nil {
+		t.Fatalf("ParseMnemonic failed: %v", err)
+	}
+	if err = first.Compatible(first); err != ErrDuplicatedShare {
+		t.Errorf("expected ErrDuplicatedShare, got %v", err)
+	}
+	other, err := Split([]byte("a secret kept in a paper safe"), 3, 2)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	words, err := EncodeMnemonic(&other[1])
+	if err != nil {
+		t.Fatalf("EncodeMnemonic failed: %v", err)
+	}
+	second, err := DecodeMnemonic(words)
+	if err != nil {
+		t.Fatalf("DecodeMnemonic failed: %v", err)
+	}
+	if err = second.Compatible(first); err == nil {
+		t.Error("expected error for a share of another secret")
+	}
+	// A share of the same length and threshold, but another digest, still recovers a wrong secret
+	second.Digest = first.Digest
+	if _, err = CombineMnemonics([]*Mnemonic{first, second}); err == nil {
+		t.Error("expected secret digest mismatch")
+	}
+}
+
+func TestMnemonic_WordList(t *testing.T) {
+	if len(mnemonicWords) != 1<<mnemonicWordBits {
+		t.Fatalf("expected %d words, got %d", 1<<mnemonicWordBits, len(mnemonicWords))
+	}
+	if len(mnemonicIndex) != len(mnemonicWords) {
+		t.Errorf("expected words told by their first four letters, got %d prefixes", len(mnemonicIndex))
+	}
+}
@@ -0,0 +1,388 @@
+package sss
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"filippo.io/edwards25519"
+)
+
+// Shares over GF(256) cannot carry Feldman or Pedersen commitments, which take a group where discrete logarithms
+// are hard. Verifiable shares are Pedersen shares over the scalars of edwards25519 instead: the secret is cut into
+// chunks of 31 bytes, each of them the intercept of a polynomial f_k of degree threshold-1, and a blinding polynomial
+// g hides them. The commitment to the coefficients of degree j is
+//
+//	C_j = a_j,1 G_1 + ... + a_j,m G_m + b_j H
+//
+// with generators G_k and H of unknown discrete logarithms, to which C_0 adds n S for the size n of the secret.
+// The share of x is f_1(x), ..., f_m(x), g(x) and n, so that
+//
+//	f_1(x) G_1 + ... + f_m(x) G_m + g(x) H + n S = C_0 + x C_1 + ... + x^(t-1) C_(t-1)
+//
+// checks the share against the commitments, which bind the dealer to one polynomial for every chunk and tell nothing
+// about the secret. The payload of a verifiable share is the uvarint size of the secret, the m+1 scalars of the share
+// in 32 bytes each and x, as the last byte like for the shares over GF(256). It is larger than the secret by the
+// scalar of the blinding and the chunks padded to 32 bytes: 98 bytes for a key of 32 bytes and 66 for one of 16,
+// against 33 and 17 over GF(256), and the t commitments of 32 bytes come on top of that.
+const pedersenChunkSize = 31
+
+const pedersenScalarSize = 32
+
+var pedersenDomain = []byte("fortify sss pedersen generator\x00")
+
+var (
+	errInvalidVerifiableShare = errors.New("invalid verifiable secret share")
+	errInvalidCommitment      = errors.New("invalid commitment")
+)
+
+// pedersenGenerators are H, S, G_1, G_2 and so on, derived as they are needed
+var pedersenGenerators struct {
+	sync.Mutex
+	points []*edwards25519.Point
+}
+
+// generators returns G_1 to G_n, then H and S
+func generators(n int) []*edwards25519.Point {
+	pedersenGenerators.Lock()
+	defer pedersenGenerators.Unlock()
+	for i := len(pedersenGenerators.points); i < n+2; i++ {
+		pedersenGenerators.points = append(pedersenGenerators.points, hashToPoint(uint64(i)))
+	}
+	return append(append([]*edwards25519.Point{}, pedersenGenerators.points[2:n+2]...), pedersenGenerators.points[:2]...)
+}
+
+// hashToPoint returns the generator of index, H for 0, S for 1 and G_k for k+1, as the first hash of the index
+// and a counter which is the encoding of a point, multiplied by the cofactor into the group of prime order
+func hashToPoint(index uint64) *edwards25519.Point {
+	var buf []byte
+	for counter := uint32(0); ; counter++ {
+		buf = append(buf[:0], pedersenDomain...)
+		buf = binary.BigEndian.AppendUint64(buf, index)
+		buf = binary.BigEndian.AppendUint32(buf, counter)
+		sum := sha512.Sum512(buf)
+		p, err := new(edwards25519.Point).SetBytes(sum[:32])
+		if err != nil {
+			continue
+		}
+		if p.MultByCofactor(p); p.Equal(edwards25519.NewIdentityPoint()) == 0 {
+			return p
+		}
+	}
+}
+
+func randomScalar() (*edwards25519.Scalar, error) {
+	var b [64]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return nil, err
+	}
+	defer clear(b[:])
+	return edwards25519.NewScalar().SetUniformBytes(b[:])
+}
+
+func scalarOf(v uint64) *edwards25519.Scalar {
+	var b [pedersenScalarSize]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	s, _ := edwards25519.NewScalar().SetCanonicalBytes(b[:])
+	return s
+}
+
+// powers returns 1, x, ..., x^(n-1)
+func powers(x uint8, n int) []*edwards25519.Scalar {
+	ps := make([]*edwards25519.Scalar, n)
+	ps[0] = scalarOf(1)
+	for j := 1; j < n; j++ {
+		ps[j] = edwards25519.NewScalar().Multiply(ps[j-1], scalarOf(uint64(x)))
+	}
+	return ps
+}
+
+// evaluate returns the value at x of the polynomial of coefficients, by Horner's method
+func evaluate(coefficients []*edwards25519.Scalar, x uint8) *edwards25519.Scalar {
+	sx := scalarOf(uint64(x))
+	y := edwards25519.NewScalar().Set(coefficients[len(coefficients)-1])
+	for j := len(coefficients) - 2; j >= 0; j-- {
+		y.MultiplyAdd(y, sx, coefficients[j])
+	}
+	return y
+}
+
+// splitPedersen returns the verifiable shares of secret at xs, and the commitments to their polynomials
+func splitPedersen(secret []byte, xs []uint8, threshold uint8) ([]Share, [][]byte, error) {
+	m := (len(secret) + pedersenChunkSize - 1) / pedersenChunkSize
+	t := int(threshold)
+	// coefficients[k][j] is the coefficient of degree j of chunk k, and coefficients[m] those of the blinding
+	coefficients := make([][]*edwards25519.Scalar, m+1)
+	for k := range coefficients {
+		coefficients[k] = make([]*edwards25519.Scalar, t)
+		for j := range t {
+			var err error
+			if j == 0 && k < m {
+				var b [pedersenScalarSize]byte
+				copy(b[:], secret[k*pedersenChunkSize:min((k+1)*pedersenChunkSize, len(secret))])
+				coefficients[k][j], err = edwards25519.NewScalar().SetCanonicalBytes(b[:])
+				clear(b[:])
+			} else {
+				coefficients[k][j], err = randomScalar()
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create polynomial: %w", err)
+			}
+		}
+	}
+	defer func() {
+		for _, c := range coefficients {
+			for _, s := range c {
+				s.Set(edwards25519.NewScalar())
+			}
+		}
+	}()
+	points := generators(m)
+	commitments := make([][]byte, t)
+	scalars := make([]*edwards25519.Scalar, m+2)
+	for j := range t {
+		for k := range m + 1 {
+			scalars[k] = coefficients[k][j]
+		}
+		scalars[m+1] = edwards25519.NewScalar()
+		if j == 0 {
+			scalars[m+1] = scalarOf(uint64(len(secret)))
+		}
+		commitments[j] = new(edwards25519.Point).MultiScalarMult(scalars, points).Bytes()
+	}
+	shares := make([]Share, len(xs))
+	for i, x := range xs {
+		share := binary.AppendUvarint(nil, uint64(len(secret)))
+		for k := range coefficients {
+			share = append(share, evaluate(coefficients[k], x).Bytes()...)
+		}
+		shares[i] = append(share, x)
+	}
+	return shares, commitments, nil
+}
+
+// pedersenShare is a decoded verifiable share
+type pedersenShare struct {
+	x    uint8
+	size int
+	ys   []*edwards25519.Scalar // The values of the polynomials of the chunks, then of the blinding one
+}
+
+func decodePedersenShare(share Share) (*pedersenShare, error) {
+	size, n := binary.Uvarint(share)
+	if n <= 0 || size == 0 || size > maxScannerTokenSize {
+		return nil, errInvalidVerifiableShare
+	}
+	m := (int(size) + pedersenChunkSize - 1) / pedersenChunkSize
+	if len(share) != n+(m+1)*pedersenScalarSize+1 {
+		return nil, errInvalidVerifiableShare
+	}
+	s := &pedersenShare{x: share[len(share)-1], size: int(size), ys: make([]*edwards25519.Scalar, m+1)}
+	if s.x == 0 {
+		return nil, errInvalidVerifiableShare
+	}
+	for k := range s.ys {
+		var err error
+		b := share[n+k*pedersenScalarSize : n+(k+1)*pedersenScalarSize]
+		if s.ys[k], err = edwards25519.NewScalar().SetCanonicalBytes(b); err != nil {
+			return nil, errInvalidVerifiableShare
+		}
+	}
+	return s, nil
+}
+
+func (s *pedersenShare) encode() Share {
+	share := binary.AppendUvarint(nil, uint64(s.size))
+	for _, y := range s.ys {
+		share = append(share, y.Bytes()...)
+	}
+	return append(share, s.x)
+}
+
+func (s *pedersenShare) clear() {
+	for _, y := range s.ys {
+		y.Set(edwards25519.NewScalar())
+	}
+}
+
+// decodeCommitments decodes commitments, which must be points of the group of prime order
+func decodeCommitments(commitments [][]byte) ([]*edwards25519.Point, error) {
+	inv8 := edwards25519.NewScalar().Invert(scalarOf(8))
+	points := make([]*edwards25519.Point, len(commitments))
+	for j, c := range commitments {
+		p, err := new(edwards25519.Point).SetBytes(c)
+		if err != nil {
+			return nil, errInvalidCommitment
+		}
+		// A point of small order in a commitment would fail the shares of some x only
+		q := new(edwards25519.Point).MultByCofactor(p)
+		if q.ScalarMult(inv8, q).Equal(p) == 0 {
+			return nil, errInvalidCommitment
+		}
+		points[j] = p
+	}
+	return points, nil
+}
+
+// verifyPedersen checks share against the commitments to its polynomials
+func verifyPedersen(share Share, commitments [][]byte) error {
+	s, err := decodePedersenShare(share)
+	if err != nil {
+		return err
+	}
+	defer s.clear()
+	cs, err := decodeCommitments(commitments)
+	if err != nil {
+		return err
+	}
+	scalars := append(append([]*edwards25519.Scalar{}, s.ys...), scalarOf(uint64(s.size)))
+	lhs := new(edwards25519.Point).MultiScalarMult(scalars, generators(len(s.ys)-1))
+	rhs := new(edwards25519.Point).VarTimeMultiScalarMult(powers(s.x, len(cs)), cs)
+	if lhs.Equal(rhs) == 0 {
+		return ErrShareMismatch
+	}
+	return nil
+}
+
+// combinePedersen recovers the secret from verifiable shares by Lagrange interpolation at 0
+func combinePedersen(shares []Share) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, ErrShareCountNotEnough
+	}
+	ss := make([]*pedersenShare, len(shares))
+	defer func() {
+		for _, s := range ss {
+			if s != nil {
+				s.clear()
+			}
+		}
+	}()
+	seen := make(map[uint8]bool, len(shares))
+	for i, share := range shares {
+		var err error
+		if ss[i], err = decodePedersenShare(share); err != nil {
+			return nil, err
+		}
+		if ss[i].size != ss[0].size {
+			return nil, fmt.Errorf("length of shares[%d] must be %d", i, len(shares[0]))
+		}
+		if seen[ss[i].x] {
+			return nil, ErrDuplicatedShare
+		}
+		seen[ss[i].x] = true
+	}
+	// lambda_i = prod_{j != i} x_j / (x_j - x_i)
+	lambdas := make([]*edwards25519.Scalar, len(ss))
+	for i := range ss {
+		num, den := scalarOf(1), scalarOf(1)
+		for j := range ss {
+			if j != i {
+				xj := scalarOf(uint64(ss[j].x))
+				num.Multiply(num, xj)
+				den.Multiply(den, edwards25519.NewScalar().Subtract(xj, scalarOf(uint64(ss[i].x))))
+			}
+		}
+		lambdas[i] = num.Multiply(num, edwards25519.NewScalar().Invert(den))
+	}
+	size := ss[0].size
+	secret := make([]byte, 0, size+1)
+	for k := range len(ss[0].ys) - 1 {
+		chunk := edwards25519.NewScalar()
+		for i, s := range ss {
+			chunk.MultiplyAdd(lambdas[i], s.ys[k], chunk)
+		}
+		b := chunk.Bytes()
+		secret = append(secret, b[:min(pedersenChunkSize, size-len(secret))]...)
+		clear(b)
+		chunk.Set(edwards25519.NewScalar())
+	}
+	return secret, nil
+}
+
+// updatePedersen returns the updates at xs of n polynomials of degree threshold-1 whose intercept is zero, for the
+// verifiable shares of n scalars, and the commitments to their coefficients, of which the first one is the identity
+func updatePedersen(n int, xs []uint8, threshold uint8) ([][]byte, [][]byte, error) {
+	t := int(threshold)
+	coefficients := make([][]*edwards25519.Scalar, n)
+	for k := range coefficients {
+		coefficients[k] = make([]*edwards25519.Scalar, t)
+		coefficients[k][0] = edwards25519.NewScalar()
+		for j := 1; j < t; j++ {
+			var err error
+			if coefficients[k][j], err = randomScalar(); err != nil {
+				return nil, nil, fmt.Errorf("failed to create polynomial: %w", err)
+			}
+		}
+	}
+	defer func() {
+		for _, c := range coefficients {
+			for _, s := range c {
+				s.Set(edwards25519.NewScalar())
+			}
+		}
+	}()
+	points := generators(n - 1)
+	commitments := make([][]byte, t)
+	scalars := make([]*edwards25519.Scalar, n+1)
+	scalars[n] = edwards25519.NewScalar()
+	for j := range t {
+		for k := range n {
+			scalars[k] = coefficients[k][j]
+		}
+		commitments[j] = new(edwards25519.Point).MultiScalarMult(scalars, points).Bytes()
+	}
+	updates := make([][]byte, len(xs))
+	for i, x := range xs {
+		for k := range coefficients {
+			updates[i] = append(updates[i], evaluate(coefficients[k], x).Bytes()...)
+		}
+	}
+	return updates, commitments, nil
+}
+
+// applyPedersen returns share plus its update, and the sums of the commitments to the polynomials of share and of
+// the update, to which the new share is verified. The update must keep the secret, with the identity as its first
+// commitment.
+func applyPedersen(share Share, update []byte, commitments, updates [][]byte) (Share, [][]byte, error) {
+	s, err := decodePedersenShare(share)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer s.clear()
+	if len(update) != len(s.ys)*pedersenScalarSize || len(updates) != len(commitments) {
+		return nil, nil, errors.New("update does not fit the secret share")
+	}
+	cs, err := decodeCommitments(commitments)
+	if err != nil {
+		return nil, nil, err
+	}
+	ds, err := decodeCommitments(updates)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ds[0].Equal(edwards25519.NewIdentityPoint()) == 0 {
+		return nil, nil, errors.New("update changes the secret")
+	}
+	for k, y := range s.ys {
+		d, err := edwards25519.NewScalar().SetCanonicalBytes(update[k*pedersenScalarSize : (k+1)*pedersenScalarSize])
+		if err != nil {
+			return nil, nil, errInvalidVerifiableShare
+		}
+		y.Add(y, d)
+		d.Set(edwards25519.NewScalar())
+	}
+	next := make([][]byte, len(cs))
+	for j := range cs {
+		next[j] = cs[j].Add(cs[j], ds[j]).Bytes()
+	}
+	share = s.encode()
+	if err = verifyPedersen(share, next); err != nil {
+		clear(share)
+		return nil, nil, fmt.Errorf("update does not match its commitments: %w", err)
+	}
+	return share, next, nil
+}
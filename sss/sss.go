@@ -19,5 +19,15 @@ type Part struct {
 	Threshold uint8     `json:"threshold"`
 	Digest    string    `json:"digest"`
 	Timestamp time.Time `json:"timestamp"`
-	file      *os.File
+	// Generation counts the refreshes of the shares, which do not combine with those of other generations
+	Generation int `json:"generation,omitempty"`
+	// Parts of the holders whose updates refreshed the share to its generation in ascending order, which are the same
+	// for all the shares of a generation
+	Holders []int `json:"holders,omitempty"`
+	// X coordinates of the shares of all parts in their order, for which the updates refreshing them are drawn
+	Xs string `json:"xs,omitempty"`
+	// Commitments of a verifiable share to the polynomials of all parts of the block, one for each degree
+	Commitments []string `json:"commitments,omitempty"`
+	file        *os.File
+	source      string // Name of the file the part is read from
 }
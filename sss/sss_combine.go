@@ -1,9 +1,7 @@
 package sss
 
 import (
-	"bufio"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -14,78 +12,93 @@ import (
 	"github.com/i3ash/fortify/utils"
 )
 
-func Combine(parts []Part) ([]byte, error) {
-	var (
-		secret []byte
-		expect string
-	)
+// Combine recovers the secret from parts, after dropping those which do not match the commitments
+// of the others, if they carry commitments. The secret must match the digest of the parts, and if all of them
+// do not recover it, as they are not verifiable, it is recovered from a threshold of them, ignoring the others
+// which disagree. It returns the errors of the parts it drops or ignores, for the caller to tell.
+// Parts of different generations are not combined.
+func Combine(parts []Part) (secret []byte, ignored []*ShareError, err error) {
+	if err = checkGenerations(parts); err != nil {
+		return
+	}
+	if parts, ignored, err = dropUnverified(parts); err != nil {
+		return
+	}
 	shares := make([]Share, len(parts))
 	for index, i := range parts {
-		if share, err := base64.URLEncoding.DecodeString(i.Payload); err != nil {
-			return secret, err
-		} else {
-			shares[index] = share
-			if len(expect) == 0 {
-				expect = i.Digest
-			} else {
-				if expect != i.Digest {
-					fmt.Printf("Expect secret digest: %s\n", expect)
-					fmt.Printf("Actual secret digest: %s\n", i.Digest)
-					return secret, fmt.Errorf("secret digest mismatch in file %v", index+1)
-				}
-			}
+		if shares[index], err = base64.URLEncoding.DecodeString(i.Payload); err != nil {
+			return nil, nil, err
 		}
 	}
-	var err error
-	if secret, err = CombineFromShares(shares); err != nil {
-		return secret, err
+	if parts[0].Verifiable() {
+		secret, err = combineVerifiable(parts, shares)
+		return
+	}
+	var disagree []*ShareError
+	if secret, disagree, err = combineTolerant(parts, shares); err != nil {
+		return nil, nil, err
+	}
+	return secret, append(ignored, disagree...), nil
+}
+
+// combineVerifiable recovers the secret from a threshold of shares, which all passed the check against
+// the commitments they agree on, and so lie on the committed polynomials
+func combineVerifiable(parts []Part, shares []Share) ([]byte, error) {
+	threshold := int(parts[0].Threshold)
+	if len(shares) < threshold {
+		return nil, fmt.Errorf("%d verified secret shares, %d needed", len(shares), threshold)
+	}
+	secret, err := combinePedersen(shares[:threshold])
+	if err != nil {
+		return nil, err
+	}
+	if utils.ComputeDigest(secret) != parts[0].Digest {
+		clear(secret)
+		return nil, ErrSecretDigestMismatch
 	}
 	return secret, nil
 }
 
+// CombineKeyFiles reads a secret share from each of args up to the first path which cannot be read,
+// where every arg is a path or another key source of files.ReadSource, which must be readable
 func CombineKeyFiles(args []string) (parts []Part, err error) {
-	size := len(args)
-	if size == 0 {
+	if len(args) == 0 {
 		return nil, nil
 	}
-	kCloseFns := make([]func(), size)
-	kParts := make([]Part, size)
-	count := 0
-	for i, name := range args {
-		var kf *os.File
-		if kf, kCloseFns[i], err = files.OpenInputFile(name); err != nil {
-			break
-		}
-		count++
+	parts = make([]Part, 0, len(args))
+	for _, name := range args {
 		var kb []byte
-		if kb, err = io.ReadAll(kf); err != nil {
-			return
+		if kb, err = files.ReadSource(name); err != nil {
+			if files.IsSource(name) {
+				return nil, err
+			}
+			break
 		}
-		if err = json.Unmarshal(kb, &kParts[i]); err != nil {
-			err = fmt.Errorf("not a valid sss key part\nCaused by: %v", err)
-			return
+		var part *Part
+		part, err = DecodePart(kb)
+		clear(kb)
+		if err != nil {
+			return nil, fmt.Errorf("not a valid sss key part\nCaused by: %v", err)
 		}
+		part.source = name
+		parts = append(parts, *part)
 	}
-	kCloseFns = kCloseFns[:count]
-	defer func() {
-		for _, kCloseFn := range kCloseFns {
-			kCloseFn()
-		}
-	}()
-	return kParts[:count], nil
+	return parts, nil
 }
 
-func CombinePartFiles(in []string, out string, truncate, verbose bool) error {
+// CombinePartFiles recovers the secret from the share files in block by block, into the file out if it is not empty.
+// It returns the errors of the shares which Combine drops or ignores, for the caller to tell.
+func CombinePartFiles(in []string, out string, truncate, verbose bool) ([]*ShareError, error) {
 	size := len(in)
 	if size == 0 {
-		return errors.New("no input files")
+		return nil, errors.New("no input files")
 	}
 	var output *os.File = nil
 	var oCloseFn func()
 	if len(out) > 0 {
 		var err error
 		if output, oCloseFn, err = files.OpenOutputFile(out, truncate); err != nil {
-			return err
+			return nil, err
 		}
 	}
 	if oCloseFn != nil {
@@ -96,7 +109,7 @@ func CombinePartFiles(in []string, out string, truncate, verbose bool) error {
 	for i, path := range in {
 		var err error
 		if iFiles[i], iCloseFn[i], err = files.OpenInputFile(path); err != nil {
-			return err
+			return nil, err
 		}
 	}
 	defer func() {
@@ -106,57 +119,54 @@ func CombinePartFiles(in []string, out string, truncate, verbose bool) error {
 		clear(iCloseFn)
 		clear(iFiles)
 	}()
-	scanners := make([]*bufio.Scanner, size)
+	readers := make([]partReader, size)
 	for i, file := range iFiles {
-		buf := make([]byte, maxScannerTokenSize)
-		scanners[i] = bufio.NewScanner(file)
-		scanners[i].Buffer(buf, maxScannerTokenSize)
-		scanners[i].Split(bufio.ScanLines)
+		var err error
+		if readers[i], err = newPartReader(file); err != nil {
+			return nil, fmt.Errorf("%s: %w", in[i], err)
+		}
 	}
 	parts := make([]Part, size)
+	// A share ignored in one block is told once, with the first error it has
+	var ignored []*ShareError
+	seen := make(map[int]bool, size)
 	count := 0
 	for {
 		var err error
-		var lines [][]byte
-		for _, scanner := range scanners {
-			if scanner.Scan() {
-				line := scanner.Bytes()
-				lines = append(lines, line)
-			}
-			if err = scanner.Err(); err != nil {
-				return err
+		read := 0
+		for i, next := range readers {
+			var p *Part
+			if p, err = next(); err == io.EOF {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("%s: %w", in[i], err)
 			}
+			parts[i] = *p
+			parts[i].source = in[i]
+			read++
 		}
-		if len(lines) != size {
+		if read != size {
 			break
 		}
-		if len(lines[0]) == 0 {
-			continue
-		}
-		for i, line := range lines {
-			if err := json.Unmarshal(line, &parts[i]); err != nil {
-				return err
-			}
-		}
 		threshold := parts[0].Threshold
 		if len(parts) < int(threshold) {
-			return errors.New(fmt.Sprintf("need %d input files", threshold))
+			return nil, errors.New(fmt.Sprintf("need %d input files", threshold))
 		}
 		block := parts[0].Block
 		blocks := parts[0].Blocks
 		if block != count+1 {
-			return errors.New("block mismatch")
+			return nil, errors.New("block mismatch")
 		}
 		var secret []byte
-		if secret, err = Combine(parts); err != nil {
-			return err
-		}
-		expect := parts[0].Digest
-		actual := utils.ComputeDigest(secret)
-		if expect != actual {
-			fmt.Printf("Expect secret digest: %s\n", expect)
-			fmt.Printf("Actual secret digest: %s\n", actual)
-			return errors.New("secret digest mismatch")
+		var blockIgnored []*ShareError
+		if secret, blockIgnored, err = Combine(parts); err != nil {
+			return nil, fmt.Errorf("block %d: %w", block, err)
+		}
+		for _, e := range blockIgnored {
+			if !seen[e.Index] {
+				seen[e.Index] = true
+				ignored = append(ignored, e)
+			}
 		}
 		if count == 0 && verbose {
 			fmt.Printf("Blocks count: %d\n", blocks)
@@ -165,21 +175,21 @@ func CombinePartFiles(in []string, out string, truncate, verbose bool) error {
 			if count == 0 {
 				var stat os.FileInfo
 				if stat, err = output.Stat(); err != nil {
-					return err
+					return nil, err
 				}
 				if stat.Size() > 0 {
 					if truncate {
 						if err = output.Truncate(0); err != nil {
-							return err
+							return nil, err
 						}
 						fmt.Printf("Truncate output file: %s\n", out)
 					} else {
-						return errors.New("output file is not empty")
+						return nil, errors.New("output file is not empty")
 					}
 				}
 			}
 			if _, err = output.Write(secret); err != nil {
-				return err
+				return nil, err
 			}
 		}
 		count++
@@ -193,7 +203,7 @@ func CombinePartFiles(in []string, out string, truncate, verbose bool) error {
 			}
 		}
 	}
-	return nil
+	return ignored, nil
 }
 
 var (
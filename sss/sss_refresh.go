@@ -0,0 +1,456 @@
+package sss
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/i3ash/fortify/files"
+	"github.com/i3ash/fortify/pkg/gf256"
+)
+
+// Refreshing shares adds to each of them the value at its x of random polynomials whose intercept is zero, so that
+// the secret is never recovered. It takes two steps, which never bring the shares together: at least a threshold of
+// holders each draw their own polynomials with NewUpdates, for the x of every part, which any share records, and send
+// the update of each part to its holder, who applies the sum of the updates received to their own share on their own.
+// Every holder must apply the updates of the same holders, or else the new shares do not lie on the same polynomials:
+// the refreshed shares record those holders, and shares refreshed by different ones neither combine nor refresh again.
+// The new shares recover the same secret, of the same digest, but they are of the next generation and do not combine
+// with the old ones: shares stolen before and after a refresh do not add up, unless the thief also gets every update
+// of the stolen share. An update is as secret as the share it applies to, and only its holder is to receive it.
+
+var ErrGenerationsMixed = errors.New("secret shares of different generations cannot be combined")
+
+var ErrHoldersMixed = errors.New("secret shares refreshed by the updates of different holders cannot be combined")
+
+var errNoXs = errors.New("secret share does not record the x of every part, which a refresh needs")
+
+// Update is the update of one block of a share to the next generation
+type Update struct {
+	Block      int       `json:"block"`
+	Blocks     int       `json:"blocks"`
+	Part       int       `json:"part"`
+	Parts      uint8     `json:"parts"`
+	Threshold  uint8     `json:"threshold"`
+	Digest     string    `json:"digest"`
+	Timestamp  time.Time `json:"timestamp"`
+	Generation int       `json:"generation"`        // Generation of the share to update
+	From       int       `json:"from"`              // Part of the holder who drew the update
+	Holders    []int     `json:"holders,omitempty"` // Holders who refreshed the share of the holder, as they did the share to update
+	// Values at the x of the part of the polynomials of the update, added to the share
+	Delta string `json:"delta"`
+	// Commitments of an update of verifiable shares to its polynomials, added to those of the shares
+	Commitments []string `json:"commitments,omitempty"`
+}
+
+// checkGenerations returns an error naming the parts which are not of the generation of the first one
+func checkGenerations(parts []Part) error {
+	var errs []error
+	for i := range parts {
+		if parts[i].Generation != parts[0].Generation {
+			errs = append(errs, &ShareError{Index: i, Source: parts[i].source,
+				Err: fmt.Errorf("generation %d, not %d", parts[i].Generation, parts[0].Generation)})
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w\n%w", ErrGenerationsMixed, errors.Join(errs...))
+	}
+	return checkHolders(parts)
+}
+
+// checkHolders returns an error naming the parts which are not refreshed by the holders of the first one
+func checkHolders(parts []Part) error {
+	var errs []error
+	for i := range parts {
+		if !slices.Equal(parts[i].Holders, parts[0].Holders) {
+			errs = append(errs, &ShareError{Index: i, Source: parts[i].source,
+				Err: fmt.Errorf("refreshed by holders %v, not %v", parts[i].Holders, parts[0].Holders)})
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w\n%w", ErrHoldersMixed, errors.Join(errs...))
+	}
+	return nil
+}
+
+// xs returns the x coordinates of the shares of all parts of p
+func (p *Part) xs() ([]uint8, error) {
+	xs, err := base64.URLEncoding.DecodeString(p.Xs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xs: %w", err)
+	}
+	if len(xs) == 0 {
+		return nil, errNoXs
+	}
+	seen := make(map[uint8]bool, len(xs))
+	for _, x := range xs {
+		if x == 0 || seen[x] {
+			return nil, fmt.Errorf("invalid xs: %w", ErrDuplicatedShare)
+		}
+		seen[x] = true
+	}
+	if len(xs) != int(p.Parts) {
+		return nil, fmt.Errorf("invalid xs: %d of them for %d parts", len(xs), p.Parts)
+	}
+	return xs, nil
+}
+
+// NewUpdates returns the updates drawn by the holder of p for every part of its block to the next generation, which
+// keep the secret. The share of p tells only the size of the shares and the x of every part.
+func NewUpdates(p *Part) ([]Update, error) {
+	if p.Threshold < 2 {
+		return nil, ErrThresholdTooSmall
+	}
+	xs, err := p.xs()
+	if err != nil {
+		return nil, err
+	}
+	share, err := base64.URLEncoding.DecodeString(p.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	defer clear(share)
+	if len(share) < 2 || p.Part < 1 || p.Part > len(xs) || share[len(share)-1] != xs[p.Part-1] {
+		return nil, errors.New("secret share does not match the x of its part")
+	}
+	var deltas, commitments [][]byte
+	if p.Verifiable() {
+		if err = p.Verify(); err != nil {
+			return nil, err
+		}
+		s, err := decodePedersenShare(share)
+		if err != nil {
+			return nil, err
+		}
+		s.clear()
+		if deltas, commitments, err = updatePedersen(len(s.ys), xs, p.Threshold); err != nil {
+			return nil, err
+		}
+	} else {
+		deltas = make([][]byte, len(xs))
+		for i := range deltas {
+			deltas[i] = make([]byte, len(share)-1)
+		}
+		for idx := range len(share) - 1 {
+			update, err := gf256.NewPolynomial(0, p.Threshold-1, rand.Reader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create polynomial: %w", err)
+			}
+			for i, x := range xs {
+				deltas[i][idx] = gf256.PolynomialEvaluate(update, x)
+			}
+			clear(update)
+		}
+	}
+	cs := encodeCommitments(commitments)
+	updates := make([]Update, len(xs))
+	now := time.Now()
+	for i := range updates {
+		updates[i] = Update{
+			Block:       p.Block,
+			Blocks:      p.Blocks,
+			Part:        i + 1,
+			Parts:       p.Parts,
+			Threshold:   p.Threshold,
+			Digest:      p.Digest,
+			Timestamp:   now,
+			Generation:  p.Generation,
+			From:        p.Part,
+			Holders:     p.Holders,
+			Delta:       base64.URLEncoding.EncodeToString(deltas[i]),
+			Commitments: cs,
+		}
+		clear(deltas[i])
+	}
+	return updates, nil
+}
+
+// Apply returns the share of p updated to the next generation by the sum of updates, which at least a threshold of
+// holders draw, one each, and whose updates every other share applies as well. A verifiable share is verified before
+// and after each of them.
+func (p *Part) Apply(updates []Update) (*Part, error) {
+	from := make(map[int]bool, len(updates))
+	for i := range updates {
+		u := &updates[i]
+		if u.Part != p.Part || u.Parts != p.Parts || u.Threshold != p.Threshold || u.Digest != p.Digest ||
+			u.Block != p.Block || u.Blocks != p.Blocks {
+			return nil, errors.New("update of another part, secret or block")
+		}
+		if u.Generation != p.Generation {
+			return nil, fmt.Errorf("%w: update of generation %d, share of generation %d",
+				ErrGenerationsMixed, u.Generation, p.Generation)
+		}
+		if !slices.Equal(u.Holders, p.Holders) {
+			return nil, fmt.Errorf("%w: update from a share refreshed by holders %v, share refreshed by holders %v",
+				ErrHoldersMixed, u.Holders, p.Holders)
+		}
+		if (len(u.Commitments) > 0) != p.Verifiable() {
+			return nil, errors.New("update and secret share are not both verifiable")
+		}
+		if u.From < 1 || u.From > int(p.Parts) || from[u.From] {
+			return nil, fmt.Errorf("update from part %d: %w", u.From, ErrDuplicatedShare)
+		}
+		from[u.From] = true
+	}
+	if len(from) < int(p.Threshold) {
+		return nil, fmt.Errorf("updates from %d parts, at least %d are required", len(from), p.Threshold)
+	}
+	share, err := base64.URLEncoding.DecodeString(p.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	defer func() { clear(share) }()
+	next := Part{
+		Block:      p.Block,
+		Blocks:     p.Blocks,
+		Part:       p.Part,
+		Parts:      p.Parts,
+		Threshold:  p.Threshold,
+		Digest:     p.Digest,
+		Timestamp:  time.Now(),
+		Generation: p.Generation + 1,
+		Holders:    slices.Sorted(maps.Keys(from)),
+		Xs:         p.Xs,
+	}
+	var cs [][]byte
+	if p.Verifiable() {
+		if err = p.Verify(); err != nil {
+			return nil, err
+		}
+		if cs, err = p.commitments(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range updates {
+		refreshed, sums, err := applyUpdate(share, cs, &updates[i])
+		if err != nil {
+			return nil, fmt.Errorf("update from part %d: %w", updates[i].From, err)
+		}
+		share, cs = refreshed, sums
+	}
+	if p.Verifiable() {
+		next.Commitments = encodeCommitments(cs)
+	}
+	next.Payload = base64.URLEncoding.EncodeToString(share)
+	return &next, nil
+}
+
+// applyUpdate returns share plus the delta of u, clearing share if it is replaced, and for a verifiable share the
+// sums of its commitments cs and those of u
+func applyUpdate(share Share, cs [][]byte, u *Update) (Share, [][]byte, error) {
+	delta, err := base64.URLEncoding.DecodeString(u.Delta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid update: %w", err)
+	}
+	defer clear(delta)
+	if cs != nil {
+		ds, err := decodeCommitments64(u.Commitments)
+		if err != nil {
+			return nil, nil, err
+		}
+		refreshed, next, err := applyPedersen(share, delta, cs, ds)
+		if err != nil {
+			return nil, nil, err
+		}
+		clear(share)
+		return refreshed, next, nil
+	}
+	if len(share) < 2 || len(delta) != len(share)-1 {
+		return nil, nil, errors.New("update does not fit the secret share")
+	}
+	for i, d := range delta {
+		share[i] = gf256.Add(share[i], d)
+	}
+	return share, nil, nil
+}
+
+// updatePath returns the path of the update file of u
+func updatePath(prefix string, u *Update) string {
+	return fmt.Sprintf("%s%dof%d.from%d.update.json", prefix, u.Part, u.Parts, u.From)
+}
+
+// updateReader reads the blocks of an update file one by one, and returns io.EOF after the last one
+type updateReader func() (*Update, error)
+
+func newUpdateReader(r io.Reader) updateReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, maxScannerTokenSize), maxScannerTokenSize)
+	return func() (*Update, error) {
+		for scanner.Scan() {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				u := &Update{}
+				if err := json.Unmarshal(line, u); err != nil {
+					return nil, err
+				}
+				return u, nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+}
+
+// WriteUpdateFiles writes the updates drawn by the holder of the share file in for every block to an update file of
+// prefix for each part, to send to the holder of the part
+func WriteUpdateFiles(in, prefix string, truncate, verbose bool) error {
+	file, closer, err := files.OpenInputFile(in)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	next, err := newPartReader(file)
+	if err != nil {
+		return err
+	}
+	for count := 0; ; count++ {
+		p, err := next()
+		if err == io.EOF {
+			if count == 0 {
+				return errors.New("no secret share found")
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if p.Block != count+1 {
+			return errors.New("block mismatch")
+		}
+		updates, err := NewUpdates(p)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", p.Block, err)
+		}
+		for i := range updates {
+			path := updatePath(prefix, &updates[i])
+			if count == 0 {
+				if err = checkOverwrite(path, []*os.File{file}); err != nil {
+					return err
+				}
+			}
+			if err = appendUpdate(path, &updates[i], count, truncate); err != nil {
+				return err
+			}
+		}
+		if verbose {
+			w := len(fmt.Sprintf("%d", p.Blocks))
+			fmt.Printf("Block %*d/%d OK -- updates to generation %d\n", w, count+1, p.Blocks, p.Generation+1)
+		}
+	}
+}
+
+func appendUpdate(path string, u *Update, block int, truncate bool) error {
+	file, err := OpenFileForWrite(path, truncate)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if block == 0 {
+		err = file.Truncate(0)
+	} else {
+		_, err = file.WriteString(EncodingJson.separator())
+	}
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	return err
+}
+
+// ApplyUpdateFiles writes the share file in, updated block by block by the sum of the update files, to a share file
+// of prefix, which must not be any of the input files
+func ApplyUpdateFiles(in string, updates []string, prefix string, encoding Encoding, truncate, verbose bool) error {
+	if encoding == EncodingMnemonic {
+		return errors.New("a mnemonic does not record the generation of a refreshed share")
+	}
+	iFile, iCloser, err := files.OpenInputFile(in)
+	if err != nil {
+		return err
+	}
+	defer iCloser()
+	inputs := []*os.File{iFile}
+	readers := make([]updateReader, len(updates))
+	for i, update := range updates {
+		uFile, uCloser, err := files.OpenInputFile(update)
+		if err != nil {
+			return err
+		}
+		defer uCloser()
+		inputs = append(inputs, uFile)
+		readers[i] = newUpdateReader(uFile)
+	}
+	nextPart, err := newPartReader(iFile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	for count := 0; ; count++ {
+		p, err := nextPart()
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+		us := make([]Update, 0, len(readers))
+		for i, nextUpdate := range readers {
+			u, uErr := nextUpdate()
+			if uErr != nil && uErr != io.EOF {
+				return fmt.Errorf("%s: %w", updates[i], uErr)
+			}
+			if (err == io.EOF) != (uErr == io.EOF) {
+				return fmt.Errorf("%s: block count mismatch", updates[i])
+			}
+			if uErr == nil {
+				us = append(us, *u)
+			}
+		}
+		if err == io.EOF {
+			if count == 0 {
+				return errors.New("no secret share found")
+			}
+			return nil
+		}
+		if p.Block != count+1 {
+			return errors.New("block mismatch")
+		}
+		next, err := p.Apply(us)
+		if err != nil {
+			return fmt.Errorf("block %d: %w", p.Block, err)
+		}
+		if count == 0 {
+			if err = checkOverwrite(partPath(prefix, next, encoding), inputs); err != nil {
+				return err
+			}
+		}
+		if err = AppendParts([]Part{*next}, count, p.Blocks, prefix, encoding, truncate); err != nil {
+			return err
+		}
+		if verbose {
+			w := len(fmt.Sprintf("%d", p.Blocks))
+			fmt.Printf("Block %*d/%d OK -- refreshed to generation %d\n", w, count+1, p.Blocks, next.Generation)
+		}
+	}
+}
+
+// checkOverwrite returns an error if path is one of the input files
+func checkOverwrite(path string, inputs []*os.File) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	for _, file := range inputs {
+		if in, err := file.Stat(); err == nil && os.SameFile(stat, in) {
+			return fmt.Errorf("file %s would overwrite an input file, choose another prefix", path)
+		}
+	}
+	return nil
+}
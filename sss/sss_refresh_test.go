@@ -0,0 +1,242 @@
+package sss
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// refresh applies to each of parts the sum of its updates drawn from the shares of holders
+func refresh(t *testing.T, parts []Part, holders ...int) []Part {
+	t.Helper()
+	received := make([][]Update, parts[0].Parts)
+	for _, holder := range holders {
+		updates, err := NewUpdates(&parts[holder])
+		if err != nil {
+			t.Fatalf("NewUpdates failed: %v", err)
+		}
+		if len(updates) != int(parts[0].Parts) {
+			t.Fatalf("expected %d updates, got %d", parts[0].Parts, len(updates))
+		}
+		for i := range updates {
+			received[i] = append(received[i], updates[i])
+		}
+	}
+	next := make([]Part, len(parts))
+	for i := range parts {
+		p, err := parts[i].Apply(received[parts[i].Part-1])
+		if err != nil {
+			t.Fatalf("part %d: Apply failed: %v", parts[i].Part, err)
+		}
+		next[i] = *p
+	}
+	return next
+}
+
+func TestRefresh(t *testing.T) {
+	secret := []byte("a secret kept across refreshes")
+	parts, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	next := refresh(t, parts, 4, 0, 2)
+	for i := range next {
+		p := &next[i]
+		if p.Generation != 1 || p.Part != parts[i].Part || p.Parts != 5 || p.Threshold != 3 ||
+			p.Digest != parts[i].Digest || p.Xs != parts[i].Xs || !slices.Equal(p.Holders, []int{1, 3, 5}) {
+			t.Errorf("unexpected refreshed part %+v", *p)
+		}
+		if p.Payload == parts[i].Payload {
+			t.Errorf("part %d: share is not refreshed", p.Part)
+		}
+	}
+	got, _, err := Combine(next[2:])
+	if err != nil || !bytes.Equal(got, secret) {
+		t.Errorf("expected %q, got %q, %v", secret, got, err)
+	}
+
+	mixed := []Part{parts[0], next[1], next[2]}
+	if _, _, err = Combine(mixed); !errors.Is(err, ErrGenerationsMixed) {
+		t.Errorf("expected ErrGenerationsMixed, got %v", err)
+	}
+	var first, second, third []Update
+	for i, updates := range []*[]Update{&first, &second, &third} {
+		if *updates, err = NewUpdates(&parts[i]); err != nil {
+			t.Fatalf("NewUpdates failed: %v", err)
+		}
+	}
+	if _, err = parts[0].Apply([]Update{first[1], second[0], third[0]}); err == nil {
+		t.Error("expected error for the update of another part")
+	}
+	if _, err = next[0].Apply([]Update{first[0], second[0], third[0]}); !errors.Is(err, ErrGenerationsMixed) {
+		t.Errorf("expected ErrGenerationsMixed for updates applied twice, got %v", err)
+	}
+	// One holder alone, or drawing twice, cannot refresh the shares
+	if _, err = parts[0].Apply([]Update{first[0], second[0]}); err == nil {
+		t.Error("expected error for the updates of fewer holders than the threshold")
+	}
+	if _, err = parts[0].Apply([]Update{first[0], second[0], second[0]}); !errors.Is(err, ErrDuplicatedShare) {
+		t.Errorf("expected ErrDuplicatedShare for two updates of one holder, got %v", err)
+	}
+	p := parts[0]
+	p.Xs = ""
+	if _, err = NewUpdates(&p); !errors.Is(err, errNoXs) {
+		t.Errorf("expected errNoXs, got %v", err)
+	}
+	p = parts[0]
+	p.Xs = parts[1].Xs[:4]
+	if _, err = NewUpdates(&p); err == nil {
+		t.Error("expected error for the xs of fewer parts")
+	}
+
+	// The generation, the xs and the holders survive every encoding which records them
+	next[0].Blocks = 1
+	for _, encoding := range []Encoding{EncodingJson, EncodingBinary, EncodingArmor} {
+		b, err := encoding.marshal(&next[0])
+		if err != nil {
+			t.Fatalf("%s: marshal failed: %v", encoding, err)
+		}
+		if p, err := DecodePart(b); err != nil || p.Generation != 1 || p.Xs != next[0].Xs ||
+			!slices.Equal(p.Holders, next[0].Holders) {
+			t.Errorf("%s: expected a part of generation 1, got %+v, %v", encoding, p, err)
+		}
+	}
+	if _, err = EncodeMnemonic(&next[0]); err == nil {
+		t.Error("expected a mnemonic to refuse a refreshed share")
+	}
+}
+
+func TestRefresh_DifferentHolders(t *testing.T) {
+	secret := []byte("a secret of four holders")
+	parts, err := Split(secret, 4, 2)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	// Parts 1 and 2 apply the updates of holders 1 and 2, parts 3 and 4 those of holders 3 and 4
+	first := refresh(t, parts, 0, 1)
+	second := refresh(t, parts, 2, 3)
+	next := []Part{first[0], first[1], second[2], second[3]}
+	if _, _, err = Combine(next[:2]); err != nil {
+		t.Errorf("expected the shares refreshed by the same holders to combine, got %v", err)
+	}
+	if _, _, err = Combine(next[1:3]); !errors.Is(err, ErrHoldersMixed) {
+		t.Errorf("expected ErrHoldersMixed, got %v", err)
+	}
+	if _, _, err = Combine(next[:3]); !errors.Is(err, ErrHoldersMixed) {
+		t.Errorf("expected ErrHoldersMixed for a threshold refreshed by the same holders and another, got %v", err)
+	}
+	// Nor do the updates drawn from shares refreshed by different holders apply
+	updates, err := NewUpdates(&next[2])
+	if err != nil {
+		t.Fatalf("NewUpdates failed: %v", err)
+	}
+	others, err := NewUpdates(&next[0])
+	if err != nil {
+		t.Fatalf("NewUpdates failed: %v", err)
+	}
+	if _, err = next[0].Apply([]Update{others[0], updates[0]}); !errors.Is(err, ErrHoldersMixed) {
+		t.Errorf("expected ErrHoldersMixed for an update from a share refreshed by other holders, got %v", err)
+	}
+}
+
+func TestRefresh_Verifiable(t *testing.T) {
+	secret := bytes.Repeat([]byte("verifiable "), 4)
+	parts, err := SplitVerifiable(secret, 4, 2)
+	if err != nil {
+		t.Fatalf("SplitVerifiable failed: %v", err)
+	}
+	next := refresh(t, parts, 1, 3)
+	for i := range next {
+		if err = next[i].Verify(); err != nil {
+			t.Errorf("part %d: %v", next[i].Part, err)
+		}
+		if next[i].Commitments[0] != parts[i].Commitments[0] || next[i].Commitments[1] == parts[i].Commitments[1] {
+			t.Errorf("part %d: unexpected commitments", next[i].Part)
+		}
+	}
+	got, _, err := Combine(next[2:])
+	if err != nil || !bytes.Equal(got, secret) {
+		t.Errorf("expected %q, got %q, %v", secret, got, err)
+	}
+
+	updates, err := NewUpdates(&parts[0])
+	if err != nil {
+		t.Fatalf("NewUpdates failed: %v", err)
+	}
+	others, err := NewUpdates(&parts[1])
+	if err != nil {
+		t.Fatalf("NewUpdates failed: %v", err)
+	}
+	u := updates[0]
+	u.Delta = tamper(u.Delta)
+	if _, err = parts[0].Apply([]Update{others[0], u}); err == nil {
+		t.Error("expected error for an update which does not match its commitments")
+	}
+	u = updates[0]
+	u.Commitments = []string{u.Commitments[1], u.Commitments[1]}
+	if _, err = parts[0].Apply([]Update{others[0], u}); err == nil {
+		t.Error("expected error for an update which changes the secret")
+	}
+	plain, err := Split(secret, 4, 2)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	plain[0].Digest = parts[0].Digest
+	if _, err = plain[0].Apply([]Update{updates[0], others[0]}); err == nil {
+		t.Error("expected error for the update of a verifiable share")
+	}
+}
+
+func TestUpdateFiles(t *testing.T) {
+	defer CloseAllFilesForWrite()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "secret")
+	secret := bytes.Repeat([]byte("refresh "), fileBlockSize/4)
+	if err := os.WriteFile(in, secret, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	old := filepath.Join(dir, "old.")
+	if err := SplitIntoFiles(in, 3, 2, false, old, EncodingBinary, true, false); err != nil {
+		t.Fatalf("SplitIntoFiles failed: %v", err)
+	}
+	CloseAllFilesForWrite()
+	update := filepath.Join(dir, "update.")
+	for _, holder := range []string{"2of3", "3of3"} {
+		if err := WriteUpdateFiles(old+holder+".bin", update, true, false); err != nil {
+			t.Fatalf("WriteUpdateFiles failed: %v", err)
+		}
+	}
+	CloseAllFilesForWrite()
+	received := func(part string) []string {
+		return []string{update + part + ".from2.update.json", update + part + ".from3.update.json"}
+	}
+	if err := ApplyUpdateFiles(old+"1of3.bin", received("1of3"), old, EncodingBinary, true, false); err == nil {
+		t.Error("expected error for overwriting the input file")
+	}
+	refreshed := filepath.Join(dir, "new.")
+	if err := ApplyUpdateFiles(old+"1of3.bin", received("3of3"), refreshed, EncodingJson, true, false); err == nil {
+		t.Error("expected error for the updates of another part")
+	}
+	if err := ApplyUpdateFiles(old+"1of3.bin", received("1of3")[:1], refreshed, EncodingJson, true, false); err == nil {
+		t.Error("expected error for the updates of fewer holders than the threshold")
+	}
+	for _, part := range []string{"1of3", "3of3"} {
+		if err := ApplyUpdateFiles(old+part+".bin", received(part), refreshed, EncodingJson, true, false); err != nil {
+			t.Fatalf("ApplyUpdateFiles failed: %v", err)
+		}
+	}
+	CloseAllFilesForWrite()
+	out := filepath.Join(dir, "out")
+	if _, err := CombinePartFiles([]string{refreshed + "1of3.json", refreshed + "3of3.json"}, out, true, false); err != nil {
+		t.Fatalf("CombinePartFiles failed: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, secret) {
+		t.Errorf("expected %d bytes of the secret, got %d bytes", len(secret), len(got))
+	}
+	if _, err := CombinePartFiles([]string{old + "2of3.bin", refreshed + "3of3.json"}, out, true, false); !errors.Is(err, ErrGenerationsMixed) {
+		t.Errorf("expected ErrGenerationsMixed, got %v", err)
+	}
+}
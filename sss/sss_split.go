@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -23,8 +22,40 @@ func Split(secret []byte, parts, threshold uint8) ([]Part, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newParts(secret, out, parts, threshold), nil
+}
+
+// SplitVerifiable splits secret into verifiable shares, which carry the commitments every holder checks them against.
+// Their payload takes a scalar of 32 bytes for each chunk of 31 bytes of the secret and one more for the blinding,
+// 98 bytes for a key of 32 bytes against 33 for the other shares, and each of them carries the commitments,
+// 32 bytes for each degree, on top of that. They also take longer to split, verify and combine.
+func SplitVerifiable(secret []byte, parts, threshold uint8) ([]Part, error) {
+	if err := checkSplit(secret, parts, threshold); err != nil {
+		return nil, err
+	}
+	xs, err := generateSecureXCoordinates(parts)
+	if err != nil {
+		return nil, err
+	}
+	out, commitments, err := splitPedersen(secret, xs, threshold)
+	if err != nil {
+		return nil, err
+	}
+	ps := newParts(secret, out, parts, threshold)
+	cs := encodeCommitments(commitments)
+	for i := range ps {
+		ps[i].Commitments = cs
+	}
+	return ps, nil
+}
+
+func newParts(secret []byte, out []Share, parts, threshold uint8) []Part {
 	var outParts []Part
 	digest := utils.ComputeDigest(secret)
+	xs := make([]byte, len(out))
+	for index, share := range out {
+		xs[index] = share[len(share)-1]
+	}
 	for index, share := range out {
 		p := Part{
 			Parts:     parts,
@@ -33,13 +64,15 @@ func Split(secret []byte, parts, threshold uint8) ([]Part, error) {
 			Timestamp: time.Now(),
 			Threshold: threshold,
 			Digest:    digest,
+			Xs:        base64.URLEncoding.EncodeToString(xs),
 		}
 		outParts = append(outParts, p)
 	}
-	return outParts, nil
+	return outParts
 }
 
-func SplitIntoFiles(in string, parts, threshold uint8, prefix string, truncate, verbose bool) error {
+// SplitIntoFiles splits the content of in block by block into share files of prefix, as verifiable shares if verifiable
+func SplitIntoFiles(in string, parts, threshold uint8, verifiable bool, prefix string, encoding Encoding, truncate, verbose bool) error {
 	file, closer, err := files.OpenInputFile(in)
 	if err != nil {
 		return err
@@ -50,6 +83,16 @@ func SplitIntoFiles(in string, parts, threshold uint8, prefix string, truncate,
 		return err
 	}
 	blocks := int(math.Ceil(float64(stat.Size()) / float64(fileBlockSize)))
+	if (encoding == EncodingQR || encoding == EncodingMnemonic) && blocks > 1 {
+		return fmt.Errorf("encoding %s holds one block of a share, not %d blocks of %d bytes", encoding, blocks, fileBlockSize)
+	}
+	if encoding == EncodingMnemonic && verifiable {
+		return ErrMnemonicVerifiable
+	}
+	split := Split
+	if verifiable {
+		split = SplitVerifiable
+	}
 	reader := bufio.NewReader(file)
 	buffer := make([]byte, fileBlockSize)
 	var bytesRead, block int
@@ -58,11 +101,11 @@ func SplitIntoFiles(in string, parts, threshold uint8, prefix string, truncate,
 		bytesRead, err = reader.Read(buffer)
 		if bytesRead > 0 {
 			secret := buffer[:bytesRead]
-			ps, err = Split(secret, parts, threshold)
+			ps, err = split(secret, parts, threshold)
 			if err != nil {
 				return err
 			}
-			err = AppendParts(ps, block, blocks, prefix, truncate)
+			err = AppendParts(ps, block, blocks, prefix, encoding, truncate)
 			if err != nil {
 				return err
 			}
@@ -82,15 +125,14 @@ func SplitIntoFiles(in string, parts, threshold uint8, prefix string, truncate,
 	return nil
 }
 
-func AppendParts(ps []Part, block, blocks int, prefix string, truncate bool) error {
+func AppendParts(ps []Part, block, blocks int, prefix string, encoding Encoding, truncate bool) error {
 	size := len(ps)
 	var wg sync.WaitGroup
 	wg.Add(size)
 	errCh := make(chan error, len(ps))
 	for i, p := range ps {
 		{
-			path := fmt.Sprintf("%s%dof%d.json", prefix, p.Part, p.Parts)
-			file, err := OpenFileForWrite(path, truncate)
+			file, err := OpenFileForWrite(partPath(prefix, &p, encoding), truncate)
 			if err != nil {
 				return err
 			}
@@ -100,7 +142,7 @@ func AppendParts(ps []Part, block, blocks int, prefix string, truncate bool) err
 		}
 		go func(wg *sync.WaitGroup, p Part) {
 			defer wg.Done()
-			if err := appendPart(&p, block); err != nil {
+			if err := appendPart(&p, block, encoding); err != nil {
 				errCh <- err
 				return
 			}
@@ -119,7 +161,12 @@ func AppendParts(ps []Part, block, blocks int, prefix string, truncate bool) err
 	return nil
 }
 
-func appendPart(p *Part, block int) (err error) {
+// partPath returns the path of the share file of p
+func partPath(prefix string, p *Part, encoding Encoding) string {
+	return fmt.Sprintf("%s%dof%d%s", prefix, p.Part, p.Parts, encoding.Ext())
+}
+
+func appendPart(p *Part, block int, encoding Encoding) (err error) {
 	file := p.file
 	if block == 0 {
 		if err = file.Truncate(0); err != nil {
@@ -127,12 +174,12 @@ func appendPart(p *Part, block int) (err error) {
 		}
 	}
 	var content []byte
-	content, err = json.Marshal(p)
+	content, err = encoding.marshal(p)
 	if err != nil {
 		return
 	}
 	if block > 0 {
-		_, err = file.WriteString("\n\n")
+		_, err = file.WriteString(encoding.separator())
 		if err != nil {
 			return
 		}
@@ -182,14 +229,8 @@ var (
 )
 
 func SplitIntoShares(secret []byte, parts, threshold uint8) ([]Share, error) {
-	if threshold < 2 {
-		return nil, ErrThresholdTooSmall
-	}
-	if threshold > parts {
-		return nil, ErrInvalidPartsThreshold
-	}
-	if len(secret) == 0 {
-		return nil, ErrEmptySecret
+	if err := checkSplit(secret, parts, threshold); err != nil {
+		return nil, err
 	}
 	var xs []uint8
 	if xs0, err := generateSecureXCoordinates(parts); err != nil {
@@ -216,6 +257,19 @@ func SplitIntoShares(secret []byte, parts, threshold uint8) ([]Share, error) {
 	return shares, nil
 }
 
+func checkSplit(secret []byte, parts, threshold uint8) error {
+	if threshold < 2 {
+		return ErrThresholdTooSmall
+	}
+	if threshold > parts {
+		return ErrInvalidPartsThreshold
+	}
+	if len(secret) == 0 {
+		return ErrEmptySecret
+	}
+	return nil
+}
+
 // generateSecureXCoordinates generates cryptographically secure x coordinates
 func generateSecureXCoordinates(count uint8) ([]uint8, error) {
 	// Generate random bytes for x coordinates
@@ -213,7 +213,7 @@ func TestCombine_Basic(t *testing.T) {
 		t.Fatalf("Split failed: %v", err)
 	}
 
-	recovered, err := Combine(ps[:3])
+	recovered, _, err := Combine(ps[:3])
 	if err != nil {
 		t.Fatalf("Combine failed: %v", err)
 	}
@@ -237,7 +237,7 @@ func TestCombine_DigestMismatch(t *testing.T) {
 
 	// Mix parts from different secrets
 	mixed := []Part{ps1[0], ps2[1]}
-	_, err = Combine(mixed)
+	_, _, err = Combine(mixed)
 	if err == nil {
 		t.Error("expected error when combining parts with different digests")
 	}
@@ -273,7 +273,7 @@ func TestCombineKeyFiles_Basic(t *testing.T) {
 		t.Fatalf("expected 2 parts, got %d", len(parts))
 	}
 
-	recovered, err := Combine(parts)
+	recovered, _, err := Combine(parts)
 	if err != nil {
 		t.Fatalf("Combine failed: %v", err)
 	}
@@ -392,7 +392,7 @@ func TestCombinePartFiles_EmptyOutNoPanic(t *testing.T) {
 	os.WriteFile(p2, []byte(`{}`), 0644)
 
 	// This should NOT panic. If it does, the test crashes = FAIL.
-	err := CombinePartFiles([]string{p1, p2}, "", false, false)
+	_, err := CombinePartFiles([]string{p1, p2}, "", false, false)
 	if err == nil {
 		t.Log("CombinePartFiles with empty out returned nil error (acceptable)")
 	}
@@ -436,7 +436,7 @@ func TestSplitIntoFiles_ExactBlockMultiple(t *testing.T) {
 	}
 
 	// SplitIntoFiles should succeed without error
-	err := SplitIntoFiles(inputPath, 3, 2, prefix, true, false)
+	err := SplitIntoFiles(inputPath, 3, 2, false, prefix, EncodingJson, true, false)
 	if err != nil {
 		t.Fatalf("SplitIntoFiles returned error for file of exact block size: %v", err)
 	}
@@ -453,11 +453,11 @@ func TestSplitIntoFiles_ExactBlockMultiple(t *testing.T) {
 	if err != nil {
 		t.Fatalf("CombineKeyFiles failed: %v", err)
 	}
-	recovered, err := Combine(parts)
+	recovered, _, err := Combine(parts)
 	if err != nil {
 		t.Fatalf("Combine failed: %v", err)
 	}
 	if !bytes.Equal(data, recovered) {
 		t.Errorf("recovered data length=%d, expected %d", len(recovered), len(data))
 	}
-}
\ No newline at end of file
+}
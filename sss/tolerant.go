@@ -0,0 +1,121 @@
+package sss
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/i3ash/fortify/pkg/gf256"
+	"github.com/i3ash/fortify/utils"
+)
+
+// maxCombineSubsets bounds the subsets of threshold shares which combineTolerant tries
+const maxCombineSubsets = 1000
+
+var (
+	ErrSecretDigestMismatch = errors.New("secret digest mismatch")
+	ErrShareInconsistent    = errors.New("secret share disagrees with the secret recovered from the others")
+)
+
+// combineTolerant recovers the secret from shares, the decoded payloads of parts, which matches the digest of the
+// parts. When all shares do not recover it, as one of them is corrupt, it tries the subsets of threshold shares,
+// and returns the errors of the shares which disagree with the secret recovered from the first subset which matches,
+// in their share or their digest.
+func combineTolerant(parts []Part, shares []Share) (secret []byte, disagree []*ShareError, err error) {
+	all := make([]int, len(shares))
+	for i := range all {
+		all[i] = i
+	}
+	if secret, err = combineSubset(parts, shares, all); err == nil {
+		return secret, nil, nil
+	}
+	threshold := max(int(parts[0].Threshold), 2)
+	if len(shares) <= threshold {
+		return nil, nil, err
+	}
+	subset := slices.Clone(all[:threshold])
+	for tried := 0; ; tried++ {
+		if tried == maxCombineSubsets {
+			return nil, nil, fmt.Errorf("%w, and no secret recovered after trying %d subsets of %d shares",
+				err, maxCombineSubsets, threshold)
+		}
+		if secret, _ = combineSubset(parts, shares, subset); secret != nil {
+			break
+		}
+		if !nextSubset(subset, len(shares)) {
+			return nil, nil, fmt.Errorf("%w, and no %d of the %d shares recover a secret matching its digest",
+				err, threshold, len(shares))
+		}
+	}
+	xs := make([]uint8, len(subset))
+	for i, j := range subset {
+		xs[i] = shares[j][len(shares[j])-1]
+	}
+	digest := parts[subset[0]].Digest
+	for i := range shares {
+		switch {
+		case slices.Contains(subset, i):
+		case !onPolynomial(xs, shares, subset, shares[i]):
+			disagree = append(disagree, &ShareError{Index: i, Source: parts[i].source, Err: ErrShareInconsistent})
+		case parts[i].Digest != digest:
+			disagree = append(disagree, &ShareError{Index: i, Source: parts[i].source, Err: ErrSecretDigestMismatch})
+		}
+	}
+	return secret, disagree, nil
+}
+
+// combineSubset recovers the secret from the shares of subset, which must match the digest of each of their parts
+func combineSubset(parts []Part, shares []Share, subset []int) ([]byte, error) {
+	picked := make([]Share, len(subset))
+	for i, j := range subset {
+		picked[i] = shares[j]
+	}
+	secret, err := CombineFromShares(picked)
+	if err != nil {
+		return nil, err
+	}
+	digest := utils.ComputeDigest(secret)
+	for _, j := range subset {
+		if parts[j].Digest != digest {
+			clear(secret)
+			return nil, ErrSecretDigestMismatch
+		}
+	}
+	return secret, nil
+}
+
+// nextSubset advances subset, indexes in increasing order, to the next combination of indexes below n
+func nextSubset(subset []int, n int) bool {
+	k := len(subset)
+	i := k - 1
+	for i >= 0 && subset[i] == n-k+i {
+		i--
+	}
+	if i < 0 {
+		return false
+	}
+	subset[i]++
+	for j := i + 1; j < k; j++ {
+		subset[j] = subset[j-1] + 1
+	}
+	return true
+}
+
+// onPolynomial reports whether share lies on the polynomials through the shares of subset, whose x are xs
+func onPolynomial(xs []uint8, shares []Share, subset []int, share Share) bool {
+	size := len(shares[subset[0]])
+	if len(share) != size {
+		return false
+	}
+	x := share[size-1]
+	ys := make([]uint8, len(subset))
+	for idx := range size - 1 {
+		for i, j := range subset {
+			ys[i] = shares[j][idx]
+		}
+		if gf256.InterpolatePolynomial(xs, ys, x) != share[idx] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package sss
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func testShares(t *testing.T, secret []byte, parts, threshold uint8) ([]Part, []Share) {
+	t.Helper()
+	ps, err := Split(secret, parts, threshold)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	shares := make([]Share, len(ps))
+	for i := range ps {
+		ps[i].source = string(rune('a' + i))
+		shares[i], _ = base64.URLEncoding.DecodeString(ps[i].Payload)
+	}
+	return ps, shares
+}
+
+func TestNextSubset(t *testing.T) {
+	subset := []int{0, 1, 2}
+	seen := map[[3]int]bool{[3]int(subset): true}
+	for nextSubset(subset, 5) {
+		if subset[0] >= subset[1] || subset[1] >= subset[2] || subset[2] >= 5 {
+			t.Fatalf("invalid subset %v", subset)
+		}
+		seen[[3]int(subset)] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("expected 10 subsets of 3 of 5, got %d", len(seen))
+	}
+}
+
+func TestCombineTolerant(t *testing.T) {
+	secret := []byte("a secret recovered despite bad shares")
+	parts, shares := testShares(t, secret, 6, 3)
+	// Two corrupt shares and a wrong digest among six
+	shares[0][3] ^= 1
+	shares[3][0] ^= 0x80
+	parts[4].Digest = parts[0].Digest[1:] + "A"
+	got, disagree, err := combineTolerant(parts, shares)
+	if err != nil {
+		t.Fatalf("combineTolerant failed: %v", err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("expected %q, got %q", secret, got)
+	}
+	if len(disagree) != 3 || disagree[0].Source != "a" || !errors.Is(disagree[0], ErrShareInconsistent) ||
+		disagree[1].Source != "d" || disagree[2].Source != "e" || !errors.Is(disagree[2], ErrSecretDigestMismatch) {
+		t.Errorf("expected shares a, d and e to disagree, got %v", disagree)
+	}
+
+	// Without a threshold of good shares, no secret is recovered
+	shares[1][0] ^= 1
+	if got, _, err = combineTolerant(parts, shares); err == nil {
+		t.Errorf("expected error, got %q", got)
+	}
+	if _, _, err = combineTolerant(parts[1:4], shares[1:4]); !errors.Is(err, ErrSecretDigestMismatch) {
+		t.Errorf("expected ErrSecretDigestMismatch, got %v", err)
+	}
+}
+
+func TestCombine_AllGoodShares(t *testing.T) {
+	secret := []byte("a secret of good shares")
+	parts, shares := testShares(t, secret, 5, 3)
+	got, disagree, err := combineTolerant(parts, shares)
+	if err != nil || !bytes.Equal(got, secret) || disagree != nil {
+		t.Errorf("expected %q without disagreement, got %q, %v, %v", secret, got, disagree, err)
+	}
+}